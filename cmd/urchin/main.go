@@ -0,0 +1,536 @@
+// Command urchin schedules object storage data to peers and checks the task status.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"urchinfs/config"
+	"urchinfs/dfstore"
+	"urchinfs/urchin"
+)
+
+const usage = `Usage: urchin <command> [flags] [urfs://endpoint/bucket/key]
+
+Commands:
+  schedule      schedule object to peer
+  schedule-dir  schedule folder to peer
+  status        check schedule task status of object
+  status-dir    check schedule task status of folder
+  list          list files of folder and their cache status in peer
+  wait          wait for schedule task to finish
+  meta          show object metadata
+  stat          show whether and where object is cached in peer
+  import        import local file to peer cache
+  export        export object in peer cache to local file or bucket
+  delete        delete object from peer cache
+
+The source can be given by urfs url or by -endpoint, -bucket and -key flags.
+Run 'urchin <command> -h' for flags of a command.
+
+Exit codes:
+  0  success
+  1  request failed, or config file is invalid
+  2  invalid usage
+  3  task failed or not found
+  4  timeout waiting for task
+  5  task is pending or running
+`
+
+// Exit codes.
+const (
+	exitOK             = 0
+	exitError          = 1
+	exitUsage          = 2
+	exitTaskFailed     = 3
+	exitTimeout        = 4
+	exitTaskInProgress = 5
+)
+
+// Output formats.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// options is the flags of commands.
+type options struct {
+	endpoint        string
+	bucket          string
+	key             string
+	peer            string
+	dfstoreEndpoint string
+	configPath      string
+	profile         string
+	pattern         string
+	tag             string
+	application     string
+	output          string
+	timeout         time.Duration
+	verbose         bool
+
+	// schedule flags.
+	overwrite bool
+	mode      string
+
+	// wait flags.
+	dir         bool
+	waitTimeout time.Duration
+	interval    time.Duration
+
+	// import and export flags.
+	file   string
+	target string
+}
+
+// command is a subcommand of urchin.
+type command struct {
+	name    string
+	summary string
+	flags   func(fs *flag.FlagSet, opts *options)
+	run     func(ctx context.Context, urfs urchin.Urchinfs, opts *options) (int, error)
+}
+
+var commands = []*command{
+	{
+		name:    "schedule",
+		summary: "schedule object to peer",
+		flags: func(fs *flag.FlagSet, opts *options) {
+			fs.BoolVar(&opts.overwrite, "overwrite", false, "force peer to fetch object from source again")
+		},
+		run: runSchedule,
+	},
+	{
+		name:    "schedule-dir",
+		summary: "schedule folder to peer",
+		flags: func(fs *flag.FlagSet, opts *options) {
+			fs.StringVar(&opts.mode, "mode", urchin.DirScheduleSkipExisting.String(), "how cached files are handled: skip-existing, overwrite or incremental")
+		},
+		run: runScheduleDir,
+	},
+	{
+		name:    "status",
+		summary: "check schedule task status of object",
+		run:     runStatus,
+	},
+	{
+		name:    "status-dir",
+		summary: "check schedule task status of folder",
+		run:     runStatusDir,
+	},
+	{
+		name:    "list",
+		summary: "list files of folder and their cache status in peer",
+		run:     runList,
+	},
+	{
+		name:    "wait",
+		summary: "wait for schedule task to finish",
+		flags: func(fs *flag.FlagSet, opts *options) {
+			fs.BoolVar(&opts.dir, "dir", false, "wait for folder task")
+			fs.DurationVar(&opts.waitTimeout, "wait-timeout", 0, "maximum duration of waiting, default scheduleTimeout of config file")
+			fs.DurationVar(&opts.interval, "interval", urchin.DefaultWaitInitialInterval, "interval of the first status check")
+		},
+		run: runWait,
+	},
+	{
+		name:    "meta",
+		summary: "show object metadata",
+		run:     runMeta,
+	},
+	{
+		name:    config.CmdStat,
+		summary: "show whether and where object is cached in peer",
+		run:     runStat,
+	},
+	{
+		name:    config.CmdImport,
+		summary: "import local file to peer cache",
+		flags: func(fs *flag.FlagSet, opts *options) {
+			fs.StringVar(&opts.file, "file", "", "local file to import")
+		},
+		run: runImport,
+	},
+	{
+		name:    config.CmdExport,
+		summary: "export object in peer cache to local file or bucket",
+		flags: func(fs *flag.FlagSet, opts *options) {
+			fs.StringVar(&opts.file, "file", "", "local file to export to")
+			fs.StringVar(&opts.target, "target", "", "urfs url of bucket object to export to")
+		},
+		run: runExport,
+	},
+	{
+		name:    config.CmdDelete,
+		summary: "delete object from peer cache",
+		run:     runDelete,
+	},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+		return exitOK
+	}
+
+	cmd := lookupCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "urchin: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+
+	opts := &options{}
+	fs := newFlagSet(cmd, opts, os.Stderr)
+	if err := parseArgs(fs, args[1:], opts); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+
+		fmt.Fprintf(os.Stderr, "urchin %s: %v\n", cmd.name, err)
+		return exitUsage
+	}
+
+	// Config file and environment are not command line, their errors are not usage errors.
+	urfs, err := newUrchinfs(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "urchin %s: %v\n", cmd.name, err)
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	code, err := cmd.run(ctx, urfs, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "urchin %s: %v\n", cmd.name, err)
+	}
+
+	return code
+}
+
+// lookupCommand returns command of name, it returns nil if not found.
+func lookupCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+
+	return nil
+}
+
+// newFlagSet returns flag set with common flags and flags of cmd.
+func newFlagSet(cmd *command, opts *options, output io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.Usage = func() {
+		fmt.Fprintf(output, "Usage: urchin %s [flags] [urfs://endpoint/bucket/key]\n\n%s.\n\nFlags:\n", cmd.name, cmd.summary)
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&opts.endpoint, "endpoint", "", "endpoint of source object storage")
+	fs.StringVar(&opts.bucket, "bucket", "", "bucket name of source object storage")
+	fs.StringVar(&opts.key, "key", "", "object key or folder of source object storage")
+	fs.StringVar(&opts.peer, "peer", "", "target peer host, e.g. 127.0.0.1:65004")
+	fs.StringVar(&opts.dfstoreEndpoint, "dfstore-endpoint", "", "address of the object storage service, overrides config file")
+	fs.StringVar(&opts.configPath, "config", "", "config file, default $"+config.EnvConfig+" or "+config.DefaultConfigPath())
+	fs.StringVar(&opts.profile, "profile", "", "profile of config file, default $"+config.EnvProfile+" or defaultProfile of config file")
+	fs.StringVar(&opts.output, "o", outputTable, "output format, table or json")
+	fs.StringVar(&opts.pattern, "pattern", "", "download pattern, p2p, seed-peer or source, overrides config file")
+	fs.StringVar(&opts.tag, "tag", "", "X-Dragonfly-Tag of requests, overrides config file")
+	fs.StringVar(&opts.application, "application", "", "X-Dragonfly-Application of requests, overrides config file")
+	fs.DurationVar(&opts.timeout, "timeout", 0, "timeout of a single request, overrides config file")
+	fs.BoolVar(&opts.verbose, "v", false, "print debug messages to stderr")
+
+	if cmd.flags != nil {
+		cmd.flags(fs, opts)
+	}
+
+	return fs
+}
+
+// parseArgs parses flags and the optional urfs url, flags can be given
+// before or after the url.
+func parseArgs(fs *flag.FlagSet, args []string, opts *options) error {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+
+		if fs.NArg() == 0 {
+			break
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	switch len(positional) {
+	case 0:
+	case 1:
+		endpoint, bucket, key, err := urchin.ParseUrfsURL(positional[0])
+		if err != nil {
+			return err
+		}
+
+		opts.endpoint, opts.bucket, opts.key = endpoint, bucket, key
+	default:
+		return fmt.Errorf("too many arguments %q", positional)
+	}
+
+	switch {
+	case opts.endpoint == "":
+		return errors.New("missing endpoint, use urfs url or -endpoint")
+	case opts.bucket == "":
+		return errors.New("missing bucket, use urfs url or -bucket")
+	case opts.key == "":
+		return errors.New("missing object key, use urfs url or -key")
+	case opts.peer == "":
+		return errors.New("missing -peer")
+	}
+
+	if opts.output != outputTable && opts.output != outputJSON {
+		return fmt.Errorf("invalid output format %q", opts.output)
+	}
+
+	if opts.timeout < 0 || opts.waitTimeout < 0 {
+		return errors.New("invalid negative -timeout or -wait-timeout")
+	}
+
+	return nil
+}
+
+// newUrchinfs returns urchinfs configured by flags.
+func newUrchinfs(opts *options) (urchin.Urchinfs, error) {
+	cfg, err := config.LoadDfstore(opts.configPath, opts.profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.dfstoreEndpoint != "" {
+		cfg.Endpoint = opts.dfstoreEndpoint
+	}
+
+	if opts.pattern != "" {
+		cfg.Pattern = opts.pattern
+	}
+
+	if opts.tag != "" {
+		cfg.Tag = opts.tag
+	}
+
+	if opts.application != "" {
+		cfg.Application = opts.application
+	}
+
+	if opts.timeout != 0 {
+		cfg.RequestTimeout = opts.timeout
+	}
+
+	urfsOptions := []urchin.Option{urchin.WithConfig(cfg)}
+	if opts.verbose {
+		urfsOptions = append(urfsOptions, urchin.WithLogger(log.New(os.Stderr, "", log.LstdFlags)))
+	}
+
+	return urchin.New(urfsOptions...)
+}
+
+func runSchedule(ctx context.Context, urfs urchin.Urchinfs, opts *options) (int, error) {
+	result, err := urfs.ScheduleDataToPeerByKeyWithContext(ctx, opts.endpoint, opts.bucket, opts.key, opts.peer, opts.overwrite)
+	if err != nil {
+		return exitError, err
+	}
+
+	return printed(scheduleExitCode(result), printResult(os.Stdout, opts.output, result))
+}
+
+func runScheduleDir(ctx context.Context, urfs urchin.Urchinfs, opts *options) (int, error) {
+	mode, err := urchin.ParseDirScheduleMode(opts.mode)
+	if err != nil {
+		return exitUsage, err
+	}
+
+	result, err := urfs.ScheduleDirToPeerByKeyWithModeContext(ctx, opts.endpoint, opts.bucket, opts.key, opts.peer, mode)
+	if err != nil {
+		return exitError, err
+	}
+
+	return printed(scheduleExitCode(result), printResult(os.Stdout, opts.output, result))
+}
+
+func runStatus(ctx context.Context, urfs urchin.Urchinfs, opts *options) (int, error) {
+	result, err := urfs.CheckScheduleTaskStatusByKeyWithContext(ctx, opts.endpoint, opts.bucket, opts.key, opts.peer)
+	if err != nil {
+		return exitError, err
+	}
+
+	return printed(statusExitCode(result), printResult(os.Stdout, opts.output, result))
+}
+
+func runStatusDir(ctx context.Context, urfs urchin.Urchinfs, opts *options) (int, error) {
+	result, err := urfs.CheckScheduleDirTaskStatusByKeyWithContext(ctx, opts.endpoint, opts.bucket, opts.key, opts.peer)
+	if err != nil {
+		return exitError, err
+	}
+
+	return printed(statusExitCode(result), printResult(os.Stdout, opts.output, result))
+}
+
+func runList(ctx context.Context, urfs urchin.Urchinfs, opts *options) (int, error) {
+	manifest, err := urfs.ListDirWithContext(ctx, opts.endpoint, opts.bucket, opts.key, opts.peer)
+	if err != nil {
+		return exitError, err
+	}
+
+	return printed(exitOK, printManifest(os.Stdout, opts.output, manifest))
+}
+
+func runWait(ctx context.Context, urfs urchin.Urchinfs, opts *options) (int, error) {
+	result, err := urfs.WaitForSchedule(ctx, opts.endpoint, opts.bucket, opts.key, opts.peer, &urchin.WaitOptions{
+		IsDir:           opts.dir,
+		Timeout:         opts.waitTimeout,
+		InitialInterval: opts.interval,
+		OnProgress: func(result *urchin.PeerResult) {
+			if opts.verbose {
+				fmt.Fprintf(os.Stderr, "status %s %s\n", result.Status(), result.StatusMsg)
+			}
+		},
+	})
+	if err != nil {
+		var timeoutErr *urchin.ScheduleTimeoutError
+		if errors.As(err, &timeoutErr) {
+			if timeoutErr.LastResult != nil {
+				if err := printResult(os.Stdout, opts.output, timeoutErr.LastResult); err != nil {
+					return exitError, err
+				}
+			}
+
+			return exitTimeout, err
+		}
+
+		return exitError, err
+	}
+
+	return printed(statusExitCode(result), printResult(os.Stdout, opts.output, result))
+}
+
+func runMeta(ctx context.Context, urfs urchin.Urchinfs, opts *options) (int, error) {
+	meta, err := urfs.GetMetadataWithContext(ctx, opts.endpoint, opts.bucket, opts.key, opts.peer)
+	if err != nil {
+		return exitError, err
+	}
+
+	return printed(exitOK, printMetadata(os.Stdout, opts.output, meta))
+}
+
+func runStat(ctx context.Context, urfs urchin.Urchinfs, opts *options) (int, error) {
+	result, err := urfs.StatObjectWithContext(ctx, opts.endpoint, opts.bucket, opts.key, opts.peer)
+	if err != nil {
+		if errors.Is(err, dfstore.ErrNotFound) {
+			return exitTaskFailed, errors.New("object is not cached in peer")
+		}
+
+		return exitError, err
+	}
+
+	return printed(exitOK, printResult(os.Stdout, opts.output, result))
+}
+
+func runImport(ctx context.Context, urfs urchin.Urchinfs, opts *options) (int, error) {
+	if opts.file == "" {
+		return exitUsage, errors.New("missing -file")
+	}
+
+	result, err := urfs.ImportObjectWithContext(ctx, opts.endpoint, opts.bucket, opts.key, opts.peer, opts.file)
+	if err != nil {
+		return exitError, err
+	}
+
+	return printed(scheduleExitCode(result), printResult(os.Stdout, opts.output, result))
+}
+
+func runExport(ctx context.Context, urfs urchin.Urchinfs, opts *options) (int, error) {
+	switch {
+	case opts.file == "" && opts.target == "":
+		return exitUsage, errors.New("missing -file or -target")
+	case opts.file != "" && opts.target != "":
+		return exitUsage, errors.New("-file and -target are exclusive")
+	case opts.file != "":
+		if err := urfs.ExportObjectWithContext(ctx, opts.endpoint, opts.bucket, opts.key, opts.peer, opts.file); err != nil {
+			return exitError, err
+		}
+
+		return exitOK, nil
+	}
+
+	targetEndpoint, targetBucket, targetKey, err := urchin.ParseUrfsURL(opts.target)
+	if err != nil {
+		return exitUsage, err
+	}
+
+	result, err := urfs.ExportObjectToBucketWithContext(ctx, opts.endpoint, opts.bucket, opts.key, opts.peer, targetEndpoint, targetBucket, targetKey)
+	if err != nil {
+		return exitError, err
+	}
+
+	return printed(scheduleExitCode(result), printResult(os.Stdout, opts.output, result))
+}
+
+func runDelete(ctx context.Context, urfs urchin.Urchinfs, opts *options) (int, error) {
+	if err := urfs.DeleteObjectWithContext(ctx, opts.endpoint, opts.bucket, opts.key, opts.peer); err != nil {
+		return exitError, err
+	}
+
+	return exitOK, nil
+}
+
+// printed returns code of command, or exitError if printing output fails.
+func printed(code int, err error) (int, error) {
+	if err != nil {
+		return exitError, err
+	}
+
+	return code, nil
+}
+
+// scheduleExitCode returns exit code of schedule result,
+// a task accepted by peer is a success.
+func scheduleExitCode(result *urchin.PeerResult) int {
+	status := result.Status()
+	if status.IsTerminal() && !status.IsSuccess() {
+		return exitTaskFailed
+	}
+
+	return exitOK
+}
+
+// statusExitCode returns exit code of task status.
+func statusExitCode(result *urchin.PeerResult) int {
+	status := result.Status()
+	switch {
+	case status.IsSuccess():
+		return exitOK
+	case !status.IsTerminal():
+		return exitTaskInProgress
+	default:
+		return exitTaskFailed
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"urchinfs/config"
+	"urchinfs/objectstorage"
+	"urchinfs/urchin"
+)
+
+// resultOutput is the json output of PeerResult.
+type resultOutput struct {
+	TaskID        string `json:"taskID"`
+	Status        string `json:"status"`
+	StatusCode    int    `json:"statusCode"`
+	StatusMsg     string `json:"statusMsg"`
+	ContentType   string `json:"contentType,omitempty"`
+	ContentLength int64  `json:"contentLength"`
+	DataEndpoint  string `json:"dataEndpoint,omitempty"`
+	DataRoot      string `json:"dataRoot,omitempty"`
+	DataPath      string `json:"dataPath,omitempty"`
+	SignedURL     string `json:"signedUrl,omitempty"`
+
+	BackSourceReason     string `json:"backSourceReason,omitempty"`
+	BackSourceReasonCode int    `json:"backSourceReasonCode,omitempty"`
+}
+
+// printResult prints schedule result in format.
+func printResult(w io.Writer, format string, result *urchin.PeerResult) error {
+	out := resultOutput{
+		TaskID:        result.TaskID,
+		Status:        result.Status().String(),
+		StatusCode:    result.StatusCode,
+		StatusMsg:     result.StatusMsg,
+		ContentType:   result.ContentType,
+		ContentLength: result.Size,
+		DataEndpoint:  result.DataEndpoint,
+		DataRoot:      result.DataRoot,
+		DataPath:      result.DataPath,
+		SignedURL:     result.SignedUrl,
+	}
+
+	if result.BackSourceReason != config.BackSourceReasonNone {
+		out.BackSourceReason = result.BackSourceReason.String()
+		out.BackSourceReasonCode = int(result.BackSourceReason)
+	}
+
+	if format == outputJSON {
+		return printJSON(w, out)
+	}
+
+	var backSource string
+	if out.BackSourceReason != "" {
+		backSource = fmt.Sprintf("%s (%d)", out.BackSourceReason, out.BackSourceReasonCode)
+	}
+
+	return printTable(w, [][2]string{
+		{"TASK ID", out.TaskID},
+		{"STATUS", fmt.Sprintf("%s (%d)", out.Status, out.StatusCode)},
+		{"MESSAGE", out.StatusMsg},
+		{"CONTENT TYPE", out.ContentType},
+		{"CONTENT LENGTH", fmt.Sprint(out.ContentLength)},
+		{"DATA ENDPOINT", out.DataEndpoint},
+		{"DATA ROOT", out.DataRoot},
+		{"DATA PATH", out.DataPath},
+		{"BACK SOURCE", backSource},
+	})
+}
+
+// metadataOutput is the json output of ObjectMetadata.
+type metadataOutput struct {
+	ContentType        string `json:"contentType,omitempty"`
+	ContentLength      int64  `json:"contentLength"`
+	ContentEncoding    string `json:"contentEncoding,omitempty"`
+	ContentLanguage    string `json:"contentLanguage,omitempty"`
+	ContentDisposition string `json:"contentDisposition,omitempty"`
+	ETag               string `json:"etag,omitempty"`
+	Digest             string `json:"digest,omitempty"`
+}
+
+// printMetadata prints object metadata in format.
+func printMetadata(w io.Writer, format string, meta *objectstorage.ObjectMetadata) error {
+	out := metadataOutput{
+		ContentType:        meta.ContentType,
+		ContentLength:      meta.ContentLength,
+		ContentEncoding:    meta.ContentEncoding,
+		ContentLanguage:    meta.ContentLanguage,
+		ContentDisposition: meta.ContentDisposition,
+		ETag:               meta.ETag,
+		Digest:             meta.Digest,
+	}
+
+	if format == outputJSON {
+		return printJSON(w, out)
+	}
+
+	return printTable(w, [][2]string{
+		{"CONTENT TYPE", out.ContentType},
+		{"CONTENT LENGTH", fmt.Sprint(out.ContentLength)},
+		{"CONTENT ENCODING", out.ContentEncoding},
+		{"CONTENT LANGUAGE", out.ContentLanguage},
+		{"CONTENT DISPOSITION", out.ContentDisposition},
+		{"ETAG", out.ETag},
+		{"DIGEST", out.Digest},
+	})
+}
+
+// printManifest prints files of folder in format.
+func printManifest(w io.Writer, format string, manifest *urchin.DirManifest) error {
+	if format == outputJSON {
+		return printJSON(w, manifest)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tSIZE\tCACHED\tETAG")
+	for _, e := range manifest.Entries {
+		cached := fmt.Sprint(e.Cached)
+		if e.Stale() {
+			cached = "stale"
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", e.Key, e.Size, cached, e.ETag)
+	}
+
+	return tw.Flush()
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printTable prints rows of name and value, rows with empty value are skipped.
+func printTable(w io.Writer, rows [][2]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		if row[1] == "" {
+			continue
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\n", row[0], row[1])
+	}
+
+	return tw.Flush()
+}
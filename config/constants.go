@@ -2,25 +2,69 @@
 package config
 
 import (
+	"fmt"
 	"time"
 )
 
+// BackSourceReason is the reason why peer downloads from source instead of P2P.
+type BackSourceReason int
+
 // Reason of backing to source.
 const (
-	BackSourceReasonNone          = 0
-	BackSourceReasonRegisterFail  = 1
-	BackSourceReasonMd5NotMatch   = 2
-	BackSourceReasonDownloadError = 3
-	BackSourceReasonNoSpace       = 4
-	BackSourceReasonInitError     = 5
-	BackSourceReasonWriteError    = 6
-	BackSourceReasonHostSysError  = 7
-	BackSourceReasonNodeEmpty     = 8
-	BackSourceReasonSourceError   = 10
-	BackSourceReasonUserSpecified = 100
-	ForceNotBackSourceAddition    = 1000
+	BackSourceReasonNone          BackSourceReason = 0
+	BackSourceReasonRegisterFail  BackSourceReason = 1
+	BackSourceReasonMd5NotMatch   BackSourceReason = 2
+	BackSourceReasonDownloadError BackSourceReason = 3
+	BackSourceReasonNoSpace       BackSourceReason = 4
+	BackSourceReasonInitError     BackSourceReason = 5
+	BackSourceReasonWriteError    BackSourceReason = 6
+	BackSourceReasonHostSysError  BackSourceReason = 7
+	BackSourceReasonNodeEmpty     BackSourceReason = 8
+	BackSourceReasonSourceError   BackSourceReason = 10
+	BackSourceReasonUserSpecified BackSourceReason = 100
+	ForceNotBackSourceAddition    BackSourceReason = 1000
 )
 
+// String returns name of the reason, reasons with ForceNotBackSourceAddition
+// are named ForceNotBackSource(reason).
+func (r BackSourceReason) String() string {
+	if r >= ForceNotBackSourceAddition {
+		return fmt.Sprintf("ForceNotBackSource(%s)", r-ForceNotBackSourceAddition)
+	}
+
+	switch r {
+	case BackSourceReasonNone:
+		return "None"
+	case BackSourceReasonRegisterFail:
+		return "RegisterFail"
+	case BackSourceReasonMd5NotMatch:
+		return "Md5NotMatch"
+	case BackSourceReasonDownloadError:
+		return "DownloadError"
+	case BackSourceReasonNoSpace:
+		return "NoSpace"
+	case BackSourceReasonInitError:
+		return "InitError"
+	case BackSourceReasonWriteError:
+		return "WriteError"
+	case BackSourceReasonHostSysError:
+		return "HostSysError"
+	case BackSourceReasonNodeEmpty:
+		return "NodeEmpty"
+	case BackSourceReasonSourceError:
+		return "SourceError"
+	case BackSourceReasonUserSpecified:
+		return "UserSpecified"
+	default:
+		return fmt.Sprintf("BackSourceReason(%d)", int(r))
+	}
+}
+
+// BackToSource returns whether peer downloaded from source for the reason.
+func (r BackSourceReason) BackToSource() bool {
+	return r != BackSourceReasonNone && r < ForceNotBackSourceAddition
+}
+
 // Download pattern.
 const (
 	PatternP2P      = "p2p"
@@ -47,10 +91,16 @@ const (
 	DefaultScheduleTimeout = 5 * time.Minute
 	DefaultDownloadTimeout = 5 * time.Minute
 
+	// Scheduler serves gRPC only, package scheduler queries
+	// schedulers through the manager below.
 	DefaultSchedulerSchema = "http"
 	DefaultSchedulerIP     = "127.0.0.1"
 	DefaultSchedulerPort   = 8002
 
+	DefaultManagerSchema = "http"
+	DefaultManagerIP     = "127.0.0.1"
+	DefaultManagerPort   = 8080
+
 	DefaultPieceChanSize     = 16
 	DefaultObjectMaxReplicas = 3
 )
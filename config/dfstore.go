@@ -1,13 +1,94 @@
-
-
 package config
 
 import (
 	"errors"
 	"fmt"
+	"gopkg.in/yaml.v3"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// WriteMode is the mode in which the backend is written.
+type WriteMode int
+
+const (
+	// WriteBack writes the object to backend before the request returns.
+	WriteBack WriteMode = iota
+
+	// AsyncWriteBack returns once peer has cached the object,
+	// and writes the object to backend asynchronously.
+	AsyncWriteBack
+)
+
+// ValidatePattern validates download pattern, empty pattern means the default of peer.
+func ValidatePattern(pattern string) error {
+	switch pattern {
+	case "", PatternP2P, PatternSeedPeer, PatternSource:
+		return nil
+	default:
+		return fmt.Errorf("invalid pattern %q, must be %s, %s or %s", pattern, PatternP2P, PatternSeedPeer, PatternSource)
+	}
+}
+
+// MaxObjectMaxReplicas is the upper bound of DfstoreConfig.MaxReplicas.
+const MaxObjectMaxReplicas = 100
+
+// Validate validates write mode.
+func (m WriteMode) Validate() error {
+	if m != WriteBack && m != AsyncWriteBack {
+		return fmt.Errorf("invalid write mode %d", m)
+	}
+
+	return nil
+}
+
+// String returns name of the write mode.
+func (m WriteMode) String() string {
+	switch m {
+	case WriteBack:
+		return "writeBack"
+	case AsyncWriteBack:
+		return "asyncWriteBack"
+	default:
+		return fmt.Sprintf("WriteMode(%d)", int(m))
+	}
+}
+
+// ParseWriteMode parses write mode of its name or number.
+func ParseWriteMode(s string) (WriteMode, error) {
+	for _, m := range []WriteMode{WriteBack, AsyncWriteBack} {
+		if strings.EqualFold(s, m.String()) {
+			return m, nil
+		}
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid write mode %q, must be writeBack or asyncWriteBack", s)
+	}
+
+	m := WriteMode(n)
+	return m, m.Validate()
+}
+
+// UnmarshalYAML decodes write mode of its name or number.
+func (m *WriteMode) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+
+	mode, err := ParseWriteMode(s)
+	if err != nil {
+		return err
+	}
+
+	*m = mode
+	return nil
+}
+
 type DfstoreConfig struct {
 	// Address of the object storage service.
 	Endpoint string `yaml:"endpoint,omitempty" mapstructure:"endpoint,omitempty"`
@@ -19,11 +100,30 @@ type DfstoreConfig struct {
 
 	// Mode is the mode in which the backend is written,
 	// including WriteBack and AsyncWriteBack.
-	Mode int `yaml:"mode,omitempty" mapstructure:"mode,omitempty"`
+	Mode WriteMode `yaml:"mode,omitempty" mapstructure:"mode,omitempty"`
 
 	// MaxReplicas is the maximum number of
 	// replicas of an object cache in seed peers.
-	MaxReplicas int `yaml:"maxReplicas,omitempty" mapstructure:"mode,maxReplicas"`
+	MaxReplicas int `yaml:"maxReplicas,omitempty" mapstructure:"maxReplicas,omitempty"`
+
+	// Pattern is the download pattern of peer, including p2p, seed-peer and source,
+	// empty means the default of peer.
+	Pattern string `yaml:"pattern,omitempty" mapstructure:"pattern,omitempty"`
+
+	// Tag divides tasks of the same object into different P2P overlays,
+	// it is sent by X-Dragonfly-Tag header.
+	Tag string `yaml:"tag,omitempty" mapstructure:"tag,omitempty"`
+
+	// Application is used for statistics and traffic control,
+	// it is sent by X-Dragonfly-Application header.
+	Application string `yaml:"application,omitempty" mapstructure:"application,omitempty"`
+
+	// RequestTimeout is the timeout of a single request, zero means no limit.
+	// Object data is streamed without limit once response header is received.
+	RequestTimeout time.Duration `yaml:"requestTimeout,omitempty" mapstructure:"requestTimeout,omitempty"`
+
+	// ScheduleTimeout is the timeout of waiting for a schedule task.
+	ScheduleTimeout time.Duration `yaml:"scheduleTimeout,omitempty" mapstructure:"scheduleTimeout,omitempty"`
 }
 
 // New dfstore configuration.
@@ -34,8 +134,9 @@ func NewDfstore() *DfstoreConfig {
 	}
 
 	return &DfstoreConfig{
-		Endpoint:    url.String(),
-		MaxReplicas: DefaultObjectMaxReplicas,
+		Endpoint:        url.String(),
+		MaxReplicas:     DefaultObjectMaxReplicas,
+		ScheduleTimeout: DefaultScheduleTimeout,
 	}
 }
 
@@ -44,9 +145,38 @@ func (cfg *DfstoreConfig) Validate() error {
 		return errors.New("dfstore requires parameter endpoint")
 	}
 
-	if _, err := url.ParseRequestURI(cfg.Endpoint); err != nil {
+	u, err := url.ParseRequestURI(cfg.Endpoint)
+	if err != nil {
 		return fmt.Errorf("invalid endpoint: %w", err)
 	}
 
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid endpoint %q, scheme must be http or https", cfg.Endpoint)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("invalid endpoint %q, host is required", cfg.Endpoint)
+	}
+
+	if err := cfg.Mode.Validate(); err != nil {
+		return err
+	}
+
+	if err := ValidatePattern(cfg.Pattern); err != nil {
+		return err
+	}
+
+	if cfg.MaxReplicas < 0 || cfg.MaxReplicas > MaxObjectMaxReplicas {
+		return fmt.Errorf("invalid max replicas %d, must be between 0 and %d", cfg.MaxReplicas, MaxObjectMaxReplicas)
+	}
+
+	if cfg.RequestTimeout < 0 {
+		return fmt.Errorf("invalid request timeout %s", cfg.RequestTimeout)
+	}
+
+	if cfg.ScheduleTimeout < 0 {
+		return fmt.Errorf("invalid schedule timeout %s", cfg.ScheduleTimeout)
+	}
+
 	return nil
 }
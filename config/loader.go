@@ -0,0 +1,203 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Environment variables of dfstore config, they override the config file.
+const (
+	EnvConfig          = "URCHIN_CONFIG"
+	EnvProfile         = "URCHIN_PROFILE"
+	EnvEndpoint        = "URCHIN_ENDPOINT"
+	EnvFilter          = "URCHIN_FILTER"
+	EnvMode            = "URCHIN_MODE"
+	EnvMaxReplicas     = "URCHIN_MAX_REPLICAS"
+	EnvPattern         = "URCHIN_PATTERN"
+	EnvTag             = "URCHIN_TAG"
+	EnvApplication     = "URCHIN_APPLICATION"
+	EnvRequestTimeout  = "URCHIN_REQUEST_TIMEOUT"
+	EnvScheduleTimeout = "URCHIN_SCHEDULE_TIMEOUT"
+)
+
+// DefaultProfile is the profile used if neither profile nor defaultProfile is given.
+const DefaultProfile = "default"
+
+// ConfigFile is the config file with a dfstore config per cluster, e.g.
+//
+//	defaultProfile: cluster-a
+//	profiles:
+//	  cluster-a:
+//	    endpoint: http://10.0.0.1:65004
+//	    mode: asyncWriteBack
+//	    maxReplicas: 3
+//	    requestTimeout: 30s
+//	    scheduleTimeout: 10m
+//
+// Fields not in profile keep the values of NewDfstore.
+type ConfigFile struct {
+	// DefaultProfile is the profile used if no profile is selected.
+	DefaultProfile string `yaml:"defaultProfile,omitempty"`
+
+	// Profiles is the dfstore configs by profile name.
+	Profiles map[string]yaml.Node `yaml:"profiles"`
+}
+
+// DefaultConfigPath returns the default path of config file, $HOME/.urchin/config.yaml.
+func DefaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".urchin", "config.yaml")
+}
+
+// LoadDfstore loads dfstore config of profile from config file at path,
+// applies environment variable overrides and validates the result.
+//
+// Empty path means URCHIN_CONFIG, then DefaultConfigPath if the file exists,
+// otherwise config starts from NewDfstore. Empty profile means URCHIN_PROFILE,
+// then defaultProfile of the file, then DefaultProfile.
+func LoadDfstore(path, profile string) (*DfstoreConfig, error) {
+	if profile == "" {
+		profile = os.Getenv(EnvProfile)
+	}
+
+	if path == "" {
+		path = os.Getenv(EnvConfig)
+	}
+
+	if path == "" {
+		if p := DefaultConfigPath(); p != "" {
+			if _, err := os.Stat(p); err == nil {
+				path = p
+			}
+		}
+	}
+
+	cfg := NewDfstore()
+	if path != "" {
+		file, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg, err = file.Dfstore(profile); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	} else if profile != "" {
+		return nil, fmt.Errorf("profile %q requires config file", profile)
+	}
+
+	if err := cfg.ApplyEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// readConfigFile reads config file at path.
+func readConfigFile(path string) (*ConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &ConfigFile{}
+	if err := yaml.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	return file, nil
+}
+
+// Dfstore returns dfstore config of profile, fields not in profile keep
+// the values of NewDfstore. Empty profile means defaultProfile of the file,
+// then DefaultProfile.
+func (f *ConfigFile) Dfstore(profile string) (*DfstoreConfig, error) {
+	if profile == "" {
+		profile = f.DefaultProfile
+	}
+
+	if profile == "" {
+		profile = DefaultProfile
+	}
+
+	node, ok := f.Profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found", profile)
+	}
+
+	cfg := NewDfstore()
+	if err := node.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("profile %q: %w", profile, err)
+	}
+
+	return cfg, nil
+}
+
+// ApplyEnv overrides fields with URCHIN_* environment variables.
+func (cfg *DfstoreConfig) ApplyEnv() error {
+	if v, ok := os.LookupEnv(EnvEndpoint); ok {
+		cfg.Endpoint = v
+	}
+
+	if v, ok := os.LookupEnv(EnvFilter); ok {
+		cfg.Filter = v
+	}
+
+	if v, ok := os.LookupEnv(EnvMode); ok {
+		mode, err := ParseWriteMode(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvMode, err)
+		}
+		cfg.Mode = mode
+	}
+
+	if v, ok := os.LookupEnv(EnvMaxReplicas); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvMaxReplicas, err)
+		}
+		cfg.MaxReplicas = n
+	}
+
+	if v, ok := os.LookupEnv(EnvPattern); ok {
+		cfg.Pattern = v
+	}
+
+	if v, ok := os.LookupEnv(EnvTag); ok {
+		cfg.Tag = v
+	}
+
+	if v, ok := os.LookupEnv(EnvApplication); ok {
+		cfg.Application = v
+	}
+
+	if v, ok := os.LookupEnv(EnvRequestTimeout); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvRequestTimeout, err)
+		}
+		cfg.RequestTimeout = d
+	}
+
+	if v, ok := os.LookupEnv(EnvScheduleTimeout); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", EnvScheduleTimeout, err)
+		}
+		cfg.ScheduleTimeout = d
+	}
+
+	return nil
+}
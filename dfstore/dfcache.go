@@ -0,0 +1,321 @@
+package dfstore
+
+import (
+	"context"
+	"errors"
+	"github.com/go-http-utils/headers"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+)
+
+// newPeerURL returns url of the operation on object in peer.
+func newPeerURL(dstPeer, endpoint, bucketName, operation, objectKey string) *url.URL {
+	return &url.URL{
+		Scheme: "http",
+		Host:   dstPeer,
+		Path:   path.Join("buckets", bucketName+"."+endpoint, operation, objectKey),
+	}
+}
+
+// StatUrfsRequestWithContext returns *http.Request of stating object in peer cache.
+func (dfs *dfstore) StatUrfsRequestWithContext(ctx context.Context, input *GetUrfsInput) (*http.Request, error) {
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+
+	u := newPeerURL(input.DstPeer, input.Endpoint, input.BucketName, "stat_object", input.ObjectKey)
+	query := u.Query()
+	if input.Filter != "" {
+		query.Set("filter", input.Filter)
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := dfs.newRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	dfs.setTaskHeaders(req, input.Tag, input.Application)
+
+	return req, nil
+}
+
+// StatUrfsWithContext returns cache status of object in peer,
+// error matches ErrNotFound if object is not cached.
+func (dfs *dfstore) StatUrfsWithContext(ctx context.Context, input *GetUrfsInput) (io.ReadCloser, error) {
+	req, err := dfs.StatUrfsRequestWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := dfs.do(req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return nil, newResponseError(resp)
+	}
+
+	return resp.Body, nil
+}
+
+// ImportUrfsInput is used to construct request of importing object to peer cache.
+type ImportUrfsInput struct {
+
+	// Endpoint is endpoint name.
+	Endpoint string
+
+	// BucketName is bucket name.
+	BucketName string
+
+	// ObjectKey is object key.
+	ObjectKey string
+
+	// Filter is used to generate a unique Task ID by
+	// filtering unnecessary query params in the URL,
+	// it is separated by & character.
+	Filter string
+
+	// DstPeer is target peerHost.
+	DstPeer string
+
+	// Tag is the X-Dragonfly-Tag of request.
+	Tag string
+
+	// Application is the X-Dragonfly-Application of request.
+	Application string
+
+	// Reader is content of object.
+	Reader io.Reader
+
+	// ContentLength is size of content, negative means unknown.
+	ContentLength int64
+}
+
+// Validate validates ImportUrfsInput fields.
+func (i *ImportUrfsInput) Validate() error {
+
+	if i.Endpoint == "" {
+		return errors.New("invalid Endpoint")
+	}
+
+	if i.BucketName == "" {
+		return errors.New("invalid BucketName")
+	}
+
+	if i.ObjectKey == "" {
+		return errors.New("invalid ObjectKey")
+	}
+
+	if i.Reader == nil {
+		return errors.New("invalid Reader")
+	}
+
+	return nil
+}
+
+// ImportUrfsRequestWithContext returns *http.Request of importing object to peer cache.
+func (dfs *dfstore) ImportUrfsRequestWithContext(ctx context.Context, input *ImportUrfsInput) (*http.Request, error) {
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+
+	u := newPeerURL(input.DstPeer, input.Endpoint, input.BucketName, "import_object", input.ObjectKey)
+	query := u.Query()
+	if input.Filter != "" {
+		query.Set("filter", input.Filter)
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := dfs.newRequestWithContext(ctx, http.MethodPut, u.String(), input.Reader)
+	if err != nil {
+		return nil, err
+	}
+	dfs.setTaskHeaders(req, input.Tag, input.Application)
+
+	if input.ContentLength >= 0 {
+		req.ContentLength = input.ContentLength
+	}
+
+	return req, nil
+}
+
+// ImportUrfsWithContext imports object to peer cache and returns import result.
+func (dfs *dfstore) ImportUrfsWithContext(ctx context.Context, input *ImportUrfsInput) (io.ReadCloser, error) {
+	req, err := dfs.ImportUrfsRequestWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := dfs.do(req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return nil, newResponseError(resp)
+	}
+
+	return resp.Body, nil
+}
+
+// ExportUrfsInput is used to construct request of exporting object from peer cache.
+type ExportUrfsInput struct {
+
+	// Endpoint is endpoint name.
+	Endpoint string
+
+	// BucketName is bucket name.
+	BucketName string
+
+	// ObjectKey is object key.
+	ObjectKey string
+
+	// Filter is used to generate a unique Task ID by
+	// filtering unnecessary query params in the URL,
+	// it is separated by & character.
+	Filter string
+
+	// Range is the HTTP range header.
+	Range string
+
+	// DstPeer is target peerHost.
+	DstPeer string
+
+	// Tag is the X-Dragonfly-Tag of request.
+	Tag string
+
+	// Application is the X-Dragonfly-Application of request.
+	Application string
+
+	// TargetEndpoint, TargetBucketName and TargetObjectKey is the bucket object
+	// exported to, content of object is returned if they are empty.
+	TargetEndpoint   string
+	TargetBucketName string
+	TargetObjectKey  string
+}
+
+// Validate validates ExportUrfsInput fields.
+func (i *ExportUrfsInput) Validate() error {
+
+	if i.Endpoint == "" {
+		return errors.New("invalid Endpoint")
+	}
+
+	if i.BucketName == "" {
+		return errors.New("invalid BucketName")
+	}
+
+	if i.ObjectKey == "" {
+		return errors.New("invalid ObjectKey")
+	}
+
+	if i.exportToBucket() && (i.TargetEndpoint == "" || i.TargetBucketName == "" || i.TargetObjectKey == "") {
+		return errors.New("invalid target, TargetEndpoint, TargetBucketName and TargetObjectKey are required")
+	}
+
+	return nil
+}
+
+// exportToBucket returns whether object is exported to a bucket.
+func (i *ExportUrfsInput) exportToBucket() bool {
+	return i.TargetEndpoint != "" || i.TargetBucketName != "" || i.TargetObjectKey != ""
+}
+
+// ExportUrfsRequestWithContext returns *http.Request of exporting object from peer cache.
+func (dfs *dfstore) ExportUrfsRequestWithContext(ctx context.Context, input *ExportUrfsInput) (*http.Request, error) {
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+
+	u := newPeerURL(input.DstPeer, input.Endpoint, input.BucketName, "export_object", input.ObjectKey)
+	query := u.Query()
+	if input.Filter != "" {
+		query.Set("filter", input.Filter)
+	}
+
+	method := http.MethodGet
+	if input.exportToBucket() {
+		method = http.MethodPost
+		query.Set("target_endpoint", input.TargetEndpoint)
+		query.Set("target_bucket", input.TargetBucketName)
+		query.Set("target_key", input.TargetObjectKey)
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := dfs.newRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	dfs.setTaskHeaders(req, input.Tag, input.Application)
+
+	if input.Range != "" {
+		req.Header.Set(headers.Range, input.Range)
+	}
+
+	return req, nil
+}
+
+// ExportUrfsWithContext returns content of object in peer cache, or the export
+// result if object is exported to a bucket.
+func (dfs *dfstore) ExportUrfsWithContext(ctx context.Context, input *ExportUrfsInput) (io.ReadCloser, error) {
+	req, err := dfs.ExportUrfsRequestWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	// Writing the same object to bucket again is harmless.
+	resp, err := dfs.do(req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return nil, newResponseError(resp)
+	}
+
+	return resp.Body, nil
+}
+
+// DeleteUrfsRequestWithContext returns *http.Request of deleting object from peer cache.
+func (dfs *dfstore) DeleteUrfsRequestWithContext(ctx context.Context, input *GetUrfsInput) (*http.Request, error) {
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+
+	u := newPeerURL(input.DstPeer, input.Endpoint, input.BucketName, "cache_object", input.ObjectKey)
+	query := u.Query()
+	if input.Filter != "" {
+		query.Set("filter", input.Filter)
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := dfs.newRequestWithContext(ctx, http.MethodDelete, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	dfs.setTaskHeaders(req, input.Tag, input.Application)
+
+	return req, nil
+}
+
+// DeleteUrfsWithContext deletes object from peer cache.
+func (dfs *dfstore) DeleteUrfsWithContext(ctx context.Context, input *GetUrfsInput) error {
+	req, err := dfs.DeleteUrfsRequestWithContext(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	resp, err := dfs.do(req, true)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return newResponseError(resp)
+	}
+
+	return resp.Body.Close()
+}
@@ -5,11 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"github.com/go-http-utils/headers"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"net/http"
 	"net/url"
 	"path"
 	"strconv"
+	"sync"
+	"time"
 	"urchinfs/config"
 	pkgobjectstorage "urchinfs/objectstorage"
 )
@@ -34,31 +38,353 @@ type Dfstore interface {
 
 	// GetUrfsStatusWithContext returns schedule status of Urfs.
 	GetUrfsStatusWithContext(ctx context.Context, input *GetUrfsInput, isDir bool) (io.ReadCloser, error)
+
+	// StatUrfsRequestWithContext returns *http.Request of stating Urfs in peer cache.
+	StatUrfsRequestWithContext(ctx context.Context, input *GetUrfsInput) (*http.Request, error)
+
+	// StatUrfsWithContext returns cache status of Urfs in peer.
+	StatUrfsWithContext(ctx context.Context, input *GetUrfsInput) (io.ReadCloser, error)
+
+	// ImportUrfsRequestWithContext returns *http.Request of importing Urfs to peer cache.
+	ImportUrfsRequestWithContext(ctx context.Context, input *ImportUrfsInput) (*http.Request, error)
+
+	// ImportUrfsWithContext imports Urfs to peer cache.
+	ImportUrfsWithContext(ctx context.Context, input *ImportUrfsInput) (io.ReadCloser, error)
+
+	// ExportUrfsRequestWithContext returns *http.Request of exporting Urfs from peer cache.
+	ExportUrfsRequestWithContext(ctx context.Context, input *ExportUrfsInput) (*http.Request, error)
+
+	// ExportUrfsWithContext exports Urfs from peer cache.
+	ExportUrfsWithContext(ctx context.Context, input *ExportUrfsInput) (io.ReadCloser, error)
+
+	// DeleteUrfsRequestWithContext returns *http.Request of deleting Urfs from peer cache.
+	DeleteUrfsRequestWithContext(ctx context.Context, input *GetUrfsInput) (*http.Request, error)
+
+	// DeleteUrfsWithContext deletes Urfs from peer cache.
+	DeleteUrfsWithContext(ctx context.Context, input *GetUrfsInput) error
+
+	// PutObjectRequestWithContext returns *http.Request of putting object.
+	PutObjectRequestWithContext(ctx context.Context, input *PutObjectInput) (*http.Request, error)
+
+	// PutObjectWithContext puts object through peer.
+	PutObjectWithContext(ctx context.Context, input *PutObjectInput) error
+
+	// GetObjectRequestWithContext returns *http.Request of getting object data from peer.
+	GetObjectRequestWithContext(ctx context.Context, input *GetUrfsInput) (*http.Request, error)
+
+	// GetObjectWithContext returns object data cached in peer and its metadata.
+	GetObjectWithContext(ctx context.Context, input *GetUrfsInput) (io.ReadCloser, *pkgobjectstorage.ObjectMetadata, error)
+
+	// ListUrfsRequestWithContext returns *http.Request of listing files in folder.
+	ListUrfsRequestWithContext(ctx context.Context, input *ListUrfsInput) (*http.Request, error)
+
+	// ListUrfsWithContext returns a page of files in folder.
+	ListUrfsWithContext(ctx context.Context, input *ListUrfsInput) (io.ReadCloser, error)
+}
+
+// Logger is the interface used by dfstore to print debug messages,
+// *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
 }
 
+// nopLogger discards all messages.
+type nopLogger struct{}
+
+func (nopLogger) Printf(format string, v ...interface{}) {}
+
 // dfstore provides object storage function.
 type dfstore struct {
-	endpoint   string
-	httpClient *http.Client
+	endpoint            string
+	httpClient          *http.Client
+	transport           http.RoundTripper
+	userAgent           string
+	headers             http.Header
+	pattern             string
+	tag                 string
+	application         string
+	requestTimeout      time.Duration
+	maxIdleConnsPerHost int
+	retryPolicy         *RetryPolicy
+	logger              Logger
+	metrics             Metrics
+	tracerProvider      trace.TracerProvider
+	tracer              trace.Tracer
+	propagator          propagation.TextMapPropagator
 }
 
 // Option is a functional option for configuring the dfstore.
 type Option func(dfs *dfstore)
 
+// WithHTTPClient set http client for dfstore.
+func WithHTTPClient(client *http.Client) Option {
+	return func(dfs *dfstore) {
+		dfs.httpClient = client
+	}
+}
+
+// WithTransport set transport of the http client, it takes precedence
+// over the transport of client set by WithHTTPClient.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(dfs *dfstore) {
+		dfs.transport = transport
+	}
+}
+
+// WithUserAgent set User-Agent header of requests.
+func WithUserAgent(userAgent string) Option {
+	return func(dfs *dfstore) {
+		dfs.userAgent = userAgent
+	}
+}
+
+// WithDefaultHeaders set headers added to every request,
+// headers set by the request itself are not overridden.
+func WithDefaultHeaders(header http.Header) Option {
+	return func(dfs *dfstore) {
+		dfs.headers = header.Clone()
+	}
+}
+
+// WithPattern set download pattern of requests without Pattern in input,
+// including config.PatternP2P, PatternSeedPeer and PatternSource.
+func WithPattern(pattern string) Option {
+	return func(dfs *dfstore) {
+		dfs.pattern = pattern
+	}
+}
+
+// WithTag set X-Dragonfly-Tag of requests without Tag in input.
+func WithTag(tag string) Option {
+	return func(dfs *dfstore) {
+		dfs.tag = tag
+	}
+}
+
+// WithApplication set X-Dragonfly-Application of requests without Application in input.
+func WithApplication(application string) Option {
+	return func(dfs *dfstore) {
+		dfs.application = application
+	}
+}
+
+// WithRequestTimeout set timeout of a single request until response header is
+// received, reading response body is only bounded by the context of request,
+// so that large objects can be streamed.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(dfs *dfstore) {
+		dfs.requestTimeout = timeout
+	}
+}
+
+// WithMaxIdleConnsPerHost set maximum idle connections kept to every peer,
+// it only takes effect when the transport is *http.Transport.
+func WithMaxIdleConnsPerHost(n int) Option {
+	return func(dfs *dfstore) {
+		dfs.maxIdleConnsPerHost = n
+	}
+}
+
+// WithLogger set logger for dfstore.
+func WithLogger(logger Logger) Option {
+	return func(dfs *dfstore) {
+		dfs.logger = logger
+	}
+}
+
 // New dfstore instance.
 func New(endpoint string, options ...Option) Dfstore {
 	dfs := &dfstore{
 		endpoint:   endpoint,
 		httpClient: http.DefaultClient,
+		logger:     nopLogger{},
 	}
 
 	for _, opt := range options {
 		opt(dfs)
 	}
+	dfs.httpClient = dfs.newHTTPClient()
+	dfs.initTracing()
 
 	return dfs
 }
 
+// newHTTPClient returns a copy of http client with transport options applied,
+// the client given by WithHTTPClient is never modified.
+func (dfs *dfstore) newHTTPClient() *http.Client {
+	if dfs.transport == nil && dfs.maxIdleConnsPerHost <= 0 {
+		return dfs.httpClient
+	}
+
+	client := *dfs.httpClient
+	if dfs.transport != nil {
+		client.Transport = dfs.transport
+	}
+
+	if dfs.maxIdleConnsPerHost > 0 {
+		transport := client.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+
+		if t, ok := transport.(*http.Transport); ok {
+			t = t.Clone()
+			t.MaxIdleConnsPerHost = dfs.maxIdleConnsPerHost
+			client.Transport = t
+		} else {
+			dfs.logger.Printf("ignore max idle conns per host, transport %T is not *http.Transport", transport)
+		}
+	}
+
+	return &client
+}
+
+// roundTrip sends request with http client, the request is canceled if response
+// header is not received within request timeout after request body is sent.
+func (dfs *dfstore) roundTrip(req *http.Request) (*http.Response, error) {
+	if dfs.requestTimeout <= 0 {
+		return dfs.httpClient.Do(req)
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	t := &headerTimer{}
+	req = req.WithContext(ctx)
+	if req.Body != nil && req.Body != http.NoBody {
+		// Transport closes request body once it is sent.
+		req.Body = &closeNotifier{ReadCloser: req.Body, onClose: func() {
+			t.start(dfs.requestTimeout, cancel)
+		}}
+	} else {
+		t.start(dfs.requestTimeout, cancel)
+	}
+
+	resp, err := dfs.httpClient.Do(req)
+	if t.stop() {
+		if err == nil {
+			resp.Body.Close()
+		}
+		cancel()
+
+		return nil, fmt.Errorf("%s %s: timeout awaiting response header after %s: %w", req.Method, req.URL, dfs.requestTimeout, context.DeadlineExceeded)
+	}
+
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// Context is released when body is closed.
+	resp.Body = &closeNotifier{ReadCloser: resp.Body, onClose: cancel}
+	return resp, nil
+}
+
+// headerTimer cancels request when it expires before response header is received.
+type headerTimer struct {
+	mu      sync.Mutex
+	timer   *time.Timer
+	stopped bool
+	expired bool
+}
+
+// start starts timer, it is a no-op if timer is started or stopped.
+func (t *headerTimer) start(timeout time.Duration, cancel context.CancelFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stopped || t.timer != nil {
+		return
+	}
+
+	t.timer = time.AfterFunc(timeout, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		if !t.stopped {
+			t.expired = true
+			cancel()
+		}
+	})
+}
+
+// stop stops timer and returns whether it has expired.
+func (t *headerTimer) stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stopped = true
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+
+	return t.expired
+}
+
+// closeNotifier calls onClose when it is closed.
+type closeNotifier struct {
+	io.ReadCloser
+	onClose func()
+}
+
+// Close closes the reader and calls onClose.
+func (r *closeNotifier) Close() error {
+	defer r.onClose()
+	return r.ReadCloser.Close()
+}
+
+// newRequestWithContext returns *http.Request with default headers and User-Agent.
+func (dfs *dfstore) newRequestWithContext(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range dfs.headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	if dfs.userAgent != "" {
+		req.Header.Set(headers.UserAgent, dfs.userAgent)
+	}
+
+	return req, nil
+}
+
+// setPattern sets pattern query param, empty pattern falls back to the pattern of client.
+func (dfs *dfstore) setPattern(query url.Values, pattern string) {
+	if pattern == "" {
+		pattern = dfs.pattern
+	}
+
+	if pattern != "" {
+		query.Set("pattern", pattern)
+	}
+}
+
+// setTaskHeaders sets X-Dragonfly-Tag and X-Dragonfly-Application of request,
+// which are the Tag and Application fields of inputs. Tag divides tasks of the
+// same object into different P2P overlays, Application is used for statistics
+// and traffic control. Empty values fall back to the defaults of client set by
+// WithTag and WithApplication.
+func (dfs *dfstore) setTaskHeaders(req *http.Request, tag, application string) {
+	if tag == "" {
+		tag = dfs.tag
+	}
+
+	if tag != "" {
+		req.Header.Set(config.HeaderDragonflyTag, tag)
+	}
+
+	if application == "" {
+		application = dfs.application
+	}
+
+	if application != "" {
+		req.Header.Set(config.HeaderDragonflyApplication, application)
+	}
+}
+
 // GetUrfsMetadataInput is used to construct request of getting object metadata.
 type GetUrfsMetadataInput struct {
 
@@ -73,6 +399,12 @@ type GetUrfsMetadataInput struct {
 
 	// DstPeer is target peerHost.
 	DstPeer string
+
+	// Tag is the X-Dragonfly-Tag of request.
+	Tag string
+
+	// Application is the X-Dragonfly-Application of request.
+	Application string
 }
 
 // Validate validates GetUrfsMetadataInput fields.
@@ -112,10 +444,11 @@ func (dfs *dfstore) GetUrfsMetadataRequestWithContext(ctx context.Context, input
 	}
 
 	u.Path = path.Join("buckets", input.BucketName+"."+input.Endpoint, "objects", input.ObjectKey)
-	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	req, err := dfs.newRequestWithContext(ctx, http.MethodHead, u.String(), nil)
 	if err != nil {
 		return nil, err
 	}
+	dfs.setTaskHeaders(req, input.Tag, input.Application)
 
 	return req, nil
 }
@@ -127,29 +460,41 @@ func (dfs *dfstore) GetUrfsMetadataWithContext(ctx context.Context, input *GetUr
 		return nil, err
 	}
 
-	resp, err := dfs.httpClient.Do(req)
+	resp, err := dfs.do(req, true)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode/100 != 2 {
-		return nil, fmt.Errorf("bad response status %s", resp.Status)
+		return nil, newResponseError(resp)
 	}
 
-	contentLength, err := strconv.ParseInt(resp.Header.Get(headers.ContentLength), 10, 64)
-	if err != nil {
-		return nil, err
+	return objectMetadataFromResponse(input.ObjectKey, resp)
+}
+
+// objectMetadataFromResponse returns object metadata of response header,
+// ContentLength is -1 if it is unknown.
+func objectMetadataFromResponse(objectKey string, resp *http.Response) (*pkgobjectstorage.ObjectMetadata, error) {
+	header := resp.Header
+	contentLength := resp.ContentLength
+	if value := header.Get(headers.ContentLength); value != "" {
+		var err error
+		contentLength, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &pkgobjectstorage.ObjectMetadata{
-		ContentDisposition: resp.Header.Get(headers.ContentDisposition),
-		ContentEncoding:    resp.Header.Get(headers.ContentEncoding),
-		ContentLanguage:    resp.Header.Get(headers.ContentLanguage),
-		ContentLength:      int64(contentLength),
-		ContentType:        resp.Header.Get(headers.ContentType),
-		ETag:               resp.Header.Get(headers.ContentType),
-		Digest:             resp.Header.Get(config.HeaderDragonflyObjectMetaDigest),
+		Key:                objectKey,
+		ContentDisposition: header.Get(headers.ContentDisposition),
+		ContentEncoding:    header.Get(headers.ContentEncoding),
+		ContentLanguage:    header.Get(headers.ContentLanguage),
+		ContentLength:      contentLength,
+		ContentType:        header.Get(headers.ContentType),
+		ETag:               header.Get(headers.ETag),
+		Digest:             header.Get(config.HeaderDragonflyObjectMetaDigest),
 	}, nil
 }
 
@@ -173,11 +518,24 @@ type GetUrfsInput struct {
 	// Range is the HTTP range header.
 	Range string
 
+	// ByteRange is the typed range of object, it takes precedence over Range.
+	ByteRange *Range
+
 	// DstPeer is target peerHost.
 	DstPeer string
 
-	// Overwrite force overwrite flag
+	// Tag is the X-Dragonfly-Tag of request.
+	Tag string
+
+	// Application is the X-Dragonfly-Application of request.
+	Application string
+
+	// Overwrite force overwrite flag, for folder all files are fetched again.
 	Overwrite bool
+
+	// Pattern is the download pattern of scheduling and getting object,
+	// including p2p, seed-peer and source, it overrides the pattern of client.
+	Pattern string
 }
 
 // GetObjectWithContext returns data of object.
@@ -187,13 +545,14 @@ func (dfs *dfstore) GetUrfsWithContext(ctx context.Context, input *GetUrfsInput,
 		return nil, err
 	}
 
-	resp, err := dfs.httpClient.Do(req)
+	// Schedule request without overwrite joins the existing task on peer.
+	resp, err := dfs.do(req, !input.Overwrite)
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.StatusCode/100 != 2 {
-		return nil, fmt.Errorf("bad response status %s", resp.Status)
+		return nil, newResponseError(resp)
 	}
 
 	return resp.Body, nil
@@ -225,18 +584,20 @@ func (dfs *dfstore) GetUrfsRequestWithContext(ctx context.Context, input *GetUrf
 		query.Set("filter", input.Filter)
 	}
 
-	if !isDir && input.Overwrite {
+	if input.Overwrite {
 		query.Set("overwrite", "1")
 	}
+	dfs.setPattern(query, input.Pattern)
 	u.RawQuery = query.Encode()
-	println("u.string", u.String())
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), nil)
+	dfs.logger.Printf("schedule request %s", u.String())
+	req, err := dfs.newRequestWithContext(ctx, http.MethodPost, u.String(), nil)
 	if err != nil {
 		return nil, err
 	}
+	dfs.setTaskHeaders(req, input.Tag, input.Application)
 
-	if input.Range != "" {
-		req.Header.Set(headers.Range, input.Range)
+	if rangeHeader := input.rangeHeader(); rangeHeader != "" {
+		req.Header.Set(headers.Range, rangeHeader)
 	}
 
 	return req, nil
@@ -259,9 +620,28 @@ func (i *GetUrfsInput) Validate() error {
 		return errors.New("invalid ObjectKey")
 	}
 
+	if i.ByteRange != nil {
+		if err := i.ByteRange.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := config.ValidatePattern(i.Pattern); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// rangeHeader returns the HTTP range header of input.
+func (i *GetUrfsInput) rangeHeader() string {
+	if i.ByteRange != nil {
+		return i.ByteRange.String()
+	}
+
+	return i.Range
+}
+
 // GetUrfsStatusWithContext returns schedule task status.
 func (dfs *dfstore) GetUrfsStatusWithContext(ctx context.Context, input *GetUrfsInput, isDir bool) (io.ReadCloser, error) {
 	req, err := dfs.GetUrfsStatusRequestWithContext(ctx, input, isDir)
@@ -269,13 +649,13 @@ func (dfs *dfstore) GetUrfsStatusWithContext(ctx context.Context, input *GetUrfs
 		return nil, err
 	}
 
-	resp, err := dfs.httpClient.Do(req)
+	resp, err := dfs.do(req, true)
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.StatusCode/100 != 2 {
-		return nil, fmt.Errorf("bad response status %s", resp.Status)
+		return nil, newResponseError(resp)
 	}
 
 	return resp.Body, nil
@@ -309,13 +689,14 @@ func (dfs *dfstore) GetUrfsStatusRequestWithContext(ctx context.Context, input *
 	}
 	u.RawQuery = query.Encode()
 	//println("u.string ", u.String())
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	req, err := dfs.newRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
 		return nil, err
 	}
+	dfs.setTaskHeaders(req, input.Tag, input.Application)
 
-	if input.Range != "" {
-		req.Header.Set(headers.Range, input.Range)
+	if rangeHeader := input.rangeHeader(); rangeHeader != "" {
+		req.Header.Set(headers.Range, rangeHeader)
 	}
 
 	return req, nil
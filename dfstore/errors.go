@@ -0,0 +1,108 @@
+package dfstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"urchinfs/config"
+)
+
+var (
+	// ErrNotFound is matched by errors of 404 responses.
+	ErrNotFound = errors.New("not found")
+
+	// ErrPeerUnavailable is matched by errors of 502, 503 and 504 responses.
+	ErrPeerUnavailable = errors.New("peer unavailable")
+
+	// ErrContentLengthMismatch means content length reported by peer
+	// is inconsistent with object metadata.
+	ErrContentLengthMismatch = errors.New("content length inconsistent with meta")
+)
+
+// maxErrorBodySize is the maximum size of response body kept in ResponseError.
+const maxErrorBodySize = 4096
+
+// ResponseError is returned when peer responds with non 2xx status.
+type ResponseError struct {
+	// Method is the request method.
+	Method string
+
+	// URL is the request url.
+	URL string
+
+	// StatusCode is the response status code.
+	StatusCode int
+
+	// Status is the response status, e.g. "404 Not Found".
+	Status string
+
+	// Body is the beginning of response body, usually error message of peer.
+	Body string
+
+	// Header is the response header.
+	Header http.Header
+
+	// BackSourceReason is the reason why peer downloaded from source,
+	// reported by BackSourceReason field of response body.
+	BackSourceReason config.BackSourceReason
+}
+
+// Error implements error.
+func (e *ResponseError) Error() string {
+	msg := fmt.Sprintf("%s %s: bad response status %s", e.Method, e.URL, e.Status)
+	if e.Body != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Body)
+	}
+
+	if e.BackSourceReason != config.BackSourceReasonNone {
+		msg = fmt.Sprintf("%s (back source reason %s)", msg, e.BackSourceReason)
+	}
+
+	return msg
+}
+
+// Is makes ResponseError match ErrNotFound and ErrPeerUnavailable by status code.
+func (e *ResponseError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrPeerUnavailable:
+		return e.StatusCode == http.StatusBadGateway ||
+			e.StatusCode == http.StatusServiceUnavailable ||
+			e.StatusCode == http.StatusGatewayTimeout
+	default:
+		return false
+	}
+}
+
+// newResponseError returns ResponseError of resp, the response body is closed.
+func newResponseError(resp *http.Response) *ResponseError {
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	return &ResponseError{
+		Method:           resp.Request.Method,
+		URL:              resp.Request.URL.String(),
+		StatusCode:       resp.StatusCode,
+		Status:           resp.Status,
+		Body:             strings.TrimSpace(string(body)),
+		Header:           resp.Header.Clone(),
+		BackSourceReason: backSourceReason(body),
+	}
+}
+
+// backSourceReason returns back source reason of response body, the same
+// field as in PeerResult of successful responses.
+func backSourceReason(body []byte) config.BackSourceReason {
+	var result struct {
+		BackSourceReason config.BackSourceReason
+	}
+	if err := json.Unmarshal(body, &result); err == nil {
+		return result.BackSourceReason
+	}
+
+	return config.BackSourceReasonNone
+}
@@ -0,0 +1,113 @@
+package dfstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// ListUrfsInput is used to construct request of listing files in folder.
+type ListUrfsInput struct {
+
+	// Endpoint is endpoint name.
+	Endpoint string
+
+	// BucketName is bucket name.
+	BucketName string
+
+	// ObjectKey is folder key.
+	ObjectKey string
+
+	// Filter is used to generate a unique Task ID by
+	// filtering unnecessary query params in the URL,
+	// it is separated by & character.
+	Filter string
+
+	// DstPeer is target peerHost.
+	DstPeer string
+
+	// Tag is the X-Dragonfly-Tag of request.
+	Tag string
+
+	// Application is the X-Dragonfly-Application of request.
+	Application string
+
+	// Marker is the key listing starts after, it is the NextMarker of previous page.
+	Marker string
+
+	// Limit is the maximum number of files in a page, zero means peer default.
+	Limit int
+}
+
+// Validate validates ListUrfsInput fields.
+func (i *ListUrfsInput) Validate() error {
+
+	if i.Endpoint == "" {
+		return errors.New("invalid Endpoint")
+	}
+
+	if i.BucketName == "" {
+		return errors.New("invalid BucketName")
+	}
+
+	if i.ObjectKey == "" {
+		return errors.New("invalid ObjectKey")
+	}
+
+	if i.Limit < 0 {
+		return errors.New("invalid Limit")
+	}
+
+	return nil
+}
+
+// ListUrfsRequestWithContext returns *http.Request of listing files in folder.
+func (dfs *dfstore) ListUrfsRequestWithContext(ctx context.Context, input *ListUrfsInput) (*http.Request, error) {
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+
+	u := newPeerURL(input.DstPeer, input.Endpoint, input.BucketName, "list_folder", input.ObjectKey)
+	query := u.Query()
+	if input.Filter != "" {
+		query.Set("filter", input.Filter)
+	}
+
+	if input.Marker != "" {
+		query.Set("marker", input.Marker)
+	}
+
+	if input.Limit > 0 {
+		query.Set("limit", strconv.Itoa(input.Limit))
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := dfs.newRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	dfs.setTaskHeaders(req, input.Tag, input.Application)
+
+	return req, nil
+}
+
+// ListUrfsWithContext returns a page of files in folder and their cache status in peer.
+func (dfs *dfstore) ListUrfsWithContext(ctx context.Context, input *ListUrfsInput) (io.ReadCloser, error) {
+	req, err := dfs.ListUrfsRequestWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := dfs.do(req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return nil, newResponseError(resp)
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,75 @@
+package dfstore
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Metrics observes requests to peers, implementations must be safe for concurrent use.
+// Operation is the kind of request, e.g. cache_object, check_object or head_object,
+// peer is the peer host of request.
+type Metrics interface {
+	// RequestStarted is called before a request is sent to peer,
+	// every retry is a separate request.
+	RequestStarted(operation, peer string)
+
+	// RequestFinished is called once response header is received or the request
+	// fails, statusCode is zero if no response is received.
+	RequestFinished(operation, peer string, statusCode int, duration time.Duration)
+
+	// BytesScheduled is called after peer accepts a schedule task of bytes.
+	BytesScheduled(peer string, bytes int64)
+}
+
+// WithMetrics set metrics observing requests to peers.
+func WithMetrics(metrics Metrics) Option {
+	return func(dfs *dfstore) {
+		dfs.metrics = metrics
+	}
+}
+
+// send sends a single request in a span and reports it to metrics.
+func (dfs *dfstore) send(req *http.Request) (*http.Response, error) {
+	operation := requestOperation(req)
+	endSpan := dfs.startRequestSpan(req, operation)
+	if dfs.metrics != nil {
+		dfs.metrics.RequestStarted(operation, req.URL.Host)
+	}
+
+	start := time.Now()
+	resp, err := dfs.roundTrip(req)
+	endSpan(resp, err)
+
+	if dfs.metrics != nil {
+		var statusCode int
+		if err == nil {
+			statusCode = resp.StatusCode
+		}
+		dfs.metrics.RequestFinished(operation, req.URL.Host, statusCode, time.Since(start))
+	}
+
+	return resp, err
+}
+
+// requestOperation returns operation of request by its path, which is
+// buckets/{bucket}.{endpoint}/{operation}/{key}.
+func requestOperation(req *http.Request) string {
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 4)
+	if len(parts) < 3 || parts[0] != "buckets" {
+		return "unknown"
+	}
+
+	if parts[2] == "objects" {
+		switch req.Method {
+		case http.MethodHead:
+			return "head_object"
+		case http.MethodGet:
+			return "get_object"
+		case http.MethodPut:
+			return "put_object"
+		}
+	}
+
+	return parts[2]
+}
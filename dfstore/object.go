@@ -0,0 +1,95 @@
+package dfstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-http-utils/headers"
+	"io"
+	"net/http"
+	pkgobjectstorage "urchinfs/objectstorage"
+)
+
+// Range is a byte range of object.
+type Range struct {
+	// Start is the offset of the first byte.
+	Start int64
+
+	// Length is the number of bytes, negative means to the end of object.
+	Length int64
+}
+
+// Validate validates Range fields.
+func (r *Range) Validate() error {
+	if r.Start < 0 {
+		return fmt.Errorf("invalid range start %d", r.Start)
+	}
+
+	if r.Length == 0 {
+		return errors.New("invalid range length 0")
+	}
+
+	return nil
+}
+
+// String returns the HTTP range header, e.g. bytes=0-1023.
+func (r *Range) String() string {
+	if r.Length < 0 {
+		return fmt.Sprintf("bytes=%d-", r.Start)
+	}
+
+	return fmt.Sprintf("bytes=%d-%d", r.Start, r.Start+r.Length-1)
+}
+
+// GetObjectRequestWithContext returns *http.Request of getting object data from peer.
+func (dfs *dfstore) GetObjectRequestWithContext(ctx context.Context, input *GetUrfsInput) (*http.Request, error) {
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+
+	u := newPeerURL(input.DstPeer, input.Endpoint, input.BucketName, "objects", input.ObjectKey)
+	query := u.Query()
+	if input.Filter != "" {
+		query.Set("filter", input.Filter)
+	}
+	dfs.setPattern(query, input.Pattern)
+	u.RawQuery = query.Encode()
+
+	req, err := dfs.newRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	dfs.setTaskHeaders(req, input.Tag, input.Application)
+
+	if rangeHeader := input.rangeHeader(); rangeHeader != "" {
+		req.Header.Set(headers.Range, rangeHeader)
+	}
+
+	return req, nil
+}
+
+// GetObjectWithContext returns object data cached in peer and its metadata,
+// ContentLength of metadata is the length of returned data when range is set.
+func (dfs *dfstore) GetObjectWithContext(ctx context.Context, input *GetUrfsInput) (io.ReadCloser, *pkgobjectstorage.ObjectMetadata, error) {
+	req, err := dfs.GetObjectRequestWithContext(ctx, input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := dfs.do(req, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return nil, nil, newResponseError(resp)
+	}
+
+	meta, err := objectMetadataFromResponse(input.ObjectKey, resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, nil, err
+	}
+
+	return resp.Body, meta, nil
+}
@@ -0,0 +1,148 @@
+package dfstore
+
+import (
+	"context"
+	"errors"
+	"github.com/go-http-utils/headers"
+	"io"
+	"net/http"
+	"strconv"
+	"urchinfs/config"
+)
+
+// PutObjectInput is used to construct request of putting object.
+type PutObjectInput struct {
+
+	// Endpoint is endpoint name.
+	Endpoint string
+
+	// BucketName is bucket name.
+	BucketName string
+
+	// ObjectKey is object key.
+	ObjectKey string
+
+	// Filter is used to generate a unique Task ID by
+	// filtering unnecessary query params in the URL,
+	// it is separated by & character.
+	Filter string
+
+	// DstPeer is target peerHost.
+	DstPeer string
+
+	// Tag is the X-Dragonfly-Tag of request.
+	Tag string
+
+	// Application is the X-Dragonfly-Application of request.
+	Application string
+
+	// Mode is the mode in which the backend is written,
+	// WriteBack blocks until backend is written, AsyncWriteBack
+	// returns once peer has cached the object.
+	Mode config.WriteMode
+
+	// MaxReplicas is the maximum number of
+	// replicas of an object cache in seed peers.
+	MaxReplicas int
+
+	// Reader is content of object.
+	Reader io.Reader
+
+	// ContentLength is size of content, negative means unknown.
+	ContentLength int64
+
+	// ContentType is Content-Type header.
+	ContentType string
+
+	// Digest is object digest in format of algorithm:hex, e.g. sha256:xxx.
+	Digest string
+}
+
+// Validate validates PutObjectInput fields.
+func (i *PutObjectInput) Validate() error {
+
+	if i.Endpoint == "" {
+		return errors.New("invalid Endpoint")
+	}
+
+	if i.BucketName == "" {
+		return errors.New("invalid BucketName")
+	}
+
+	if i.ObjectKey == "" {
+		return errors.New("invalid ObjectKey")
+	}
+
+	if i.Reader == nil {
+		return errors.New("invalid Reader")
+	}
+
+	if err := i.Mode.Validate(); err != nil {
+		return err
+	}
+
+	if i.MaxReplicas < 0 {
+		return errors.New("invalid MaxReplicas")
+	}
+
+	return nil
+}
+
+// PutObjectRequestWithContext returns *http.Request of putting object.
+func (dfs *dfstore) PutObjectRequestWithContext(ctx context.Context, input *PutObjectInput) (*http.Request, error) {
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+
+	u := newPeerURL(input.DstPeer, input.Endpoint, input.BucketName, "objects", input.ObjectKey)
+	query := u.Query()
+	query.Set("mode", strconv.Itoa(int(input.Mode)))
+	if input.Filter != "" {
+		query.Set("filter", input.Filter)
+	}
+
+	if input.MaxReplicas > 0 {
+		query.Set("maxReplicas", strconv.Itoa(input.MaxReplicas))
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := dfs.newRequestWithContext(ctx, http.MethodPut, u.String(), input.Reader)
+	if err != nil {
+		return nil, err
+	}
+	dfs.setTaskHeaders(req, input.Tag, input.Application)
+
+	if input.ContentLength >= 0 {
+		req.ContentLength = input.ContentLength
+	}
+
+	if input.ContentType != "" {
+		req.Header.Set(headers.ContentType, input.ContentType)
+	}
+
+	if input.Digest != "" {
+		req.Header.Set(config.HeaderDragonflyObjectMetaDigest, input.Digest)
+	}
+
+	return req, nil
+}
+
+// PutObjectWithContext puts object through peer, it returns after backend is
+// written in WriteBack mode, or after peer has cached the object in AsyncWriteBack mode.
+func (dfs *dfstore) PutObjectWithContext(ctx context.Context, input *PutObjectInput) error {
+	req, err := dfs.PutObjectRequestWithContext(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	resp, err := dfs.do(req, true)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return newResponseError(resp)
+	}
+
+	return resp.Body.Close()
+}
@@ -0,0 +1,178 @@
+package dfstore
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultRetryMaxAttempts is the default maximum number of attempts of a request.
+	DefaultRetryMaxAttempts = 3
+
+	// DefaultRetryInitialBackoff is the default backoff before the first retry.
+	DefaultRetryInitialBackoff = 200 * time.Millisecond
+
+	// DefaultRetryMaxBackoff is the default upper bound of backoff.
+	DefaultRetryMaxBackoff = 5 * time.Second
+
+	// DefaultRetryMultiplier is the default growth factor of backoff.
+	DefaultRetryMultiplier = 2.0
+)
+
+// RetryPolicy controls how failed requests to peer are retried.
+//
+// Requests which are safe to be sent more than once, i.e. HEAD and GET requests
+// and schedule requests without overwrite, are retried on transport errors and
+// retryable status. Other requests are only retried when the connection
+// to peer could not be established, so peer never receives them twice.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts including the first one.
+	MaxAttempts int
+
+	// InitialBackoff is the backoff before the first retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the upper bound of backoff.
+	MaxBackoff time.Duration
+
+	// Multiplier is the growth factor of backoff.
+	Multiplier float64
+
+	// RetryOnStatus reports whether response status code is retryable,
+	// default is DefaultRetryOnStatus.
+	RetryOnStatus func(statusCode int) bool
+}
+
+// DefaultRetryPolicy returns retry policy with default values.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    DefaultRetryMaxAttempts,
+		InitialBackoff: DefaultRetryInitialBackoff,
+		MaxBackoff:     DefaultRetryMaxBackoff,
+		Multiplier:     DefaultRetryMultiplier,
+		RetryOnStatus:  DefaultRetryOnStatus,
+	}
+}
+
+// DefaultRetryOnStatus retries 429 and the status of unavailable peer.
+func DefaultRetryOnStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// RetryOnStatusClass returns RetryOnStatus retrying every status
+// of the given classes, e.g. 5 for all 5xx status.
+func RetryOnStatusClass(classes ...int) func(statusCode int) bool {
+	return func(statusCode int) bool {
+		for _, class := range classes {
+			if statusCode/100 == class {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// WithRetryPolicy set retry policy of requests, requests are not retried by default.
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(dfs *dfstore) {
+		dfs.retryPolicy = policy
+	}
+}
+
+// do sends request to peer with retry policy,
+// idempotent tells whether the request is safe to be sent more than once.
+func (dfs *dfstore) do(req *http.Request, idempotent bool) (*http.Response, error) {
+	policy := dfs.retryPolicy
+	if policy == nil || policy.MaxAttempts <= 1 {
+		return dfs.send(req)
+	}
+
+	// Request body can not be replayed.
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return dfs.send(req)
+	}
+
+	retryOnStatus := policy.RetryOnStatus
+	if retryOnStatus == nil {
+		retryOnStatus = DefaultRetryOnStatus
+	}
+
+	backoff := policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		r := req
+		if attempt > 1 {
+			r = req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				r.Body = body
+			}
+		}
+
+		resp, err := dfs.send(r)
+
+		var retry bool
+		if err != nil {
+			retry = req.Context().Err() == nil && (idempotent || isDialError(err))
+		} else {
+			retry = idempotent && retryOnStatus(resp.StatusCode)
+		}
+
+		if !retry || attempt >= policy.MaxAttempts {
+			return resp, err
+		}
+
+		if err == nil {
+			dfs.logger.Printf("retry %s %s after status %s, attempt %d", req.Method, req.URL, resp.Status, attempt)
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+			resp.Body.Close()
+		} else {
+			dfs.logger.Printf("retry %s %s after error %v, attempt %d", req.Method, req.URL, err, attempt)
+		}
+
+		timer := time.NewTimer(jitter(backoff))
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if policy.Multiplier > 1 {
+			backoff = time.Duration(float64(backoff) * policy.Multiplier)
+		}
+
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+// isDialError returns whether err happens when connecting to peer,
+// in which case the request was never sent.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// jitter returns a random duration in [d/2, d).
+func jitter(d time.Duration) time.Duration {
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+
+	return time.Duration(half + rand.Int63n(half))
+}
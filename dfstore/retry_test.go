@@ -0,0 +1,178 @@
+package dfstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testRetryPolicy retries quickly so that tests do not wait for backoff.
+func testRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		Multiplier:     2,
+	}
+}
+
+// newStatusPeer returns peer responding statusCode to every request and the number of requests it received.
+func newStatusPeer(t *testing.T, statusCode int) (*httptest.Server, *int32) {
+	var hits int32
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(statusCode)
+	}))
+	t.Cleanup(peer.Close)
+
+	return peer, &hits
+}
+
+// countingTransport counts round trips of requests.
+type countingTransport struct {
+	http.RoundTripper
+	count int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.count, 1)
+	return t.RoundTripper.RoundTrip(req)
+}
+
+func scheduleInput(dstPeer string, overwrite bool) *GetUrfsInput {
+	return &GetUrfsInput{
+		Endpoint:   "endpoint",
+		BucketName: "bucket",
+		ObjectKey:  "key",
+		DstPeer:    dstPeer,
+		Overwrite:  overwrite,
+	}
+}
+
+func TestRetryOnStatus(t *testing.T) {
+	peer, hits := newStatusPeer(t, http.StatusServiceUnavailable)
+	dfs := New("", WithRetryPolicy(testRetryPolicy()))
+
+	_, err := dfs.GetUrfsWithContext(context.Background(), scheduleInput(peer.Listener.Addr().String(), false), false)
+	if !errors.Is(err, ErrPeerUnavailable) {
+		t.Fatalf("expected ErrPeerUnavailable, got %v", err)
+	}
+
+	if got := atomic.LoadInt32(hits); got != 3 {
+		t.Errorf("expected 3 requests, got %d", got)
+	}
+}
+
+func TestRetryNotRetryableStatus(t *testing.T) {
+	peer, hits := newStatusPeer(t, http.StatusInternalServerError)
+	dfs := New("", WithRetryPolicy(testRetryPolicy()))
+
+	var respErr *ResponseError
+	_, err := dfs.GetUrfsWithContext(context.Background(), scheduleInput(peer.Listener.Addr().String(), false), false)
+	if !errors.As(err, &respErr) || respErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected ResponseError of 500, got %v", err)
+	}
+
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Errorf("expected 1 request, got %d", got)
+	}
+}
+
+func TestRetryNotRetriedWithOverwrite(t *testing.T) {
+	peer, hits := newStatusPeer(t, http.StatusServiceUnavailable)
+	dfs := New("", WithRetryPolicy(testRetryPolicy()))
+
+	_, err := dfs.GetUrfsWithContext(context.Background(), scheduleInput(peer.Listener.Addr().String(), true), false)
+	if !errors.Is(err, ErrPeerUnavailable) {
+		t.Fatalf("expected ErrPeerUnavailable, got %v", err)
+	}
+
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Errorf("expected 1 request, got %d", got)
+	}
+}
+
+func TestRetryDialErrorOfNonIdempotentRequest(t *testing.T) {
+	// Address of a closed listener refuses connections.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	transport := &countingTransport{RoundTripper: http.DefaultTransport}
+	dfs := New("", WithTransport(transport), WithRetryPolicy(testRetryPolicy()))
+
+	_, err = dfs.GetUrfsWithContext(context.Background(), scheduleInput(addr, true), false)
+	if err == nil || !isDialError(err) {
+		t.Fatalf("expected dial error, got %v", err)
+	}
+
+	if got := atomic.LoadInt32(&transport.count); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestRetryNonReplayableBody(t *testing.T) {
+	peer, hits := newStatusPeer(t, http.StatusServiceUnavailable)
+	d := New("", WithRetryPolicy(testRetryPolicy())).(*dfstore)
+
+	// GetBody is only set for bytes and strings readers.
+	body := io.MultiReader(strings.NewReader("data"))
+	req, err := d.newRequestWithContext(context.Background(), http.MethodPut, peer.URL, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.GetBody != nil {
+		t.Fatal("expected request without GetBody")
+	}
+
+	resp, err := d.do(req, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", resp.StatusCode)
+	}
+
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Errorf("expected 1 request, got %d", got)
+	}
+}
+
+func TestRetryContextCanceledDuringBackoff(t *testing.T) {
+	peer, hits := newStatusPeer(t, http.StatusServiceUnavailable)
+	policy := testRetryPolicy()
+	policy.InitialBackoff = time.Minute
+	policy.MaxBackoff = time.Minute
+	dfs := New("", WithRetryPolicy(policy))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := dfs.GetUrfsWithContext(ctx, scheduleInput(peer.Listener.Addr().String(), false), false)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected cancel to stop backoff, took %s", elapsed)
+	}
+
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Errorf("expected 1 request, got %d", got)
+	}
+}
@@ -0,0 +1,103 @@
+package dfstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRequestTimeout = 100 * time.Millisecond
+
+func objectInput(dstPeer string) *GetUrfsInput {
+	return &GetUrfsInput{
+		Endpoint:   "endpoint",
+		BucketName: "bucket",
+		ObjectKey:  "key",
+		DstPeer:    dstPeer,
+	}
+}
+
+func TestRequestTimeoutAwaitingHeader(t *testing.T) {
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer peer.Close()
+
+	dfs := New("", WithRequestTimeout(testRequestTimeout))
+	_, _, err := dfs.GetObjectWithContext(context.Background(), objectInput(peer.Listener.Addr().String()))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestRequestTimeoutNotBoundingBody(t *testing.T) {
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < 4; i++ {
+			w.Write([]byte("data"))
+			w.(http.Flusher).Flush()
+			time.Sleep(testRequestTimeout)
+		}
+	}))
+	defer peer.Close()
+
+	dfs := New("", WithRequestTimeout(testRequestTimeout))
+	reader, _, err := dfs.GetObjectWithContext(context.Background(), objectInput(peer.Listener.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("expected body streamed beyond request timeout, got %v", err)
+	}
+
+	if string(data) != strings.Repeat("data", 4) {
+		t.Errorf("unexpected body %q", data)
+	}
+}
+
+// slowReader returns data of a few reads, every read takes interval.
+type slowReader struct {
+	reads    int
+	interval time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if r.reads == 0 {
+		return 0, io.EOF
+	}
+	r.reads--
+	time.Sleep(r.interval)
+
+	return copy(p, "data"), nil
+}
+
+func TestRequestTimeoutNotBoundingUpload(t *testing.T) {
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer peer.Close()
+
+	dfs := New("", WithRequestTimeout(testRequestTimeout))
+	err := dfs.PutObjectWithContext(context.Background(), &PutObjectInput{
+		Endpoint:      "endpoint",
+		BucketName:    "bucket",
+		ObjectKey:     "key",
+		DstPeer:       peer.Listener.Addr().String(),
+		Reader:        &slowReader{reads: 4, interval: testRequestTimeout},
+		ContentLength: 16,
+	})
+	if err != nil {
+		t.Fatalf("expected upload beyond request timeout, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package dfstore
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// tracerName is the instrumentation name of spans of requests to peers.
+const tracerName = "urchinfs/dfstore"
+
+// WithTracerProvider set tracer provider of request spans,
+// default is the global provider of otel.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(dfs *dfstore) {
+		dfs.tracerProvider = provider
+	}
+}
+
+// WithPropagator set propagator injecting trace context to requests,
+// default is W3C trace context.
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(dfs *dfstore) {
+		dfs.propagator = propagator
+	}
+}
+
+// initTracing sets the defaults of tracing.
+func (dfs *dfstore) initTracing() {
+	if dfs.tracerProvider == nil {
+		dfs.tracerProvider = otel.GetTracerProvider()
+	}
+
+	if dfs.propagator == nil {
+		dfs.propagator = propagation.TraceContext{}
+	}
+
+	dfs.tracer = dfs.tracerProvider.Tracer(tracerName)
+}
+
+// startRequestSpan starts client span of request and injects its trace context
+// to request header, the returned func ends the span with result of request.
+func (dfs *dfstore) startRequestSpan(req *http.Request, operation string) func(resp *http.Response, err error) {
+	ctx, span := dfs.tracer.Start(req.Context(), "dfstore."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("urchin.operation", operation),
+			attribute.String("http.method", req.Method),
+			attribute.String("http.url", req.URL.String()),
+			attribute.String("net.peer.name", req.URL.Host),
+		),
+	)
+	dfs.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	return func(resp *http.Response, err error) {
+		defer span.End()
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return
+		}
+
+		span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+		if resp.StatusCode/100 != 2 {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	}
+}
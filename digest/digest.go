@@ -0,0 +1,181 @@
+// Package digest parses and verifies object digests in format of algorithm:hex,
+// which is carried by X-Dragonfly-Object-Meta-Digest header.
+package digest
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+	"urchinfs/config"
+)
+
+// Supported digest algorithms.
+const (
+	AlgorithmMD5    = "md5"
+	AlgorithmSHA1   = "sha1"
+	AlgorithmSHA256 = "sha256"
+	AlgorithmCRC32C = "crc32c"
+)
+
+// ErrDigestMismatch is matched by errors of data not matching its digest.
+var ErrDigestMismatch = errors.New("digest mismatch")
+
+// MismatchError is returned when data does not match its digest.
+type MismatchError struct {
+	Algorithm string
+	Expected  string
+	Actual    string
+}
+
+// Error implements error.
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("%s: %s expected %s, actual %s", ErrDigestMismatch, e.Algorithm, e.Expected, e.Actual)
+}
+
+// Is makes MismatchError match ErrDigestMismatch.
+func (e *MismatchError) Is(target error) bool {
+	return target == ErrDigestMismatch
+}
+
+// BackSourceReason returns the back-to-source reason peer reports for
+// the same failure, which is config.BackSourceReasonMd5NotMatch.
+func (e *MismatchError) BackSourceReason() config.BackSourceReason {
+	return config.BackSourceReasonMd5NotMatch
+}
+
+// Digest is a parsed object digest.
+type Digest struct {
+	// Algorithm is the hash algorithm, e.g. sha256.
+	Algorithm string
+
+	// Encoded is the hex encoded hash value.
+	Encoded string
+}
+
+// Parse parses digest in format of algorithm:hex.
+func Parse(s string) (*Digest, error) {
+	algorithm, encoded, found := strings.Cut(strings.TrimSpace(s), ":")
+	if !found {
+		return nil, fmt.Errorf("invalid digest %q, e.g. sha256:hex", s)
+	}
+
+	d := &Digest{
+		Algorithm: strings.ToLower(algorithm),
+		Encoded:   strings.ToLower(encoded),
+	}
+
+	h, err := d.NewHash()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := hex.DecodeString(d.Encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid digest %q: %w", s, err)
+	}
+
+	if len(raw) != h.Size() {
+		return nil, fmt.Errorf("invalid digest %q, %s requires %d bytes", s, d.Algorithm, h.Size())
+	}
+
+	return d, nil
+}
+
+// String returns digest in format of algorithm:hex.
+func (d *Digest) String() string {
+	return d.Algorithm + ":" + d.Encoded
+}
+
+// NewHash returns hash of the digest algorithm.
+func (d *Digest) NewHash() (hash.Hash, error) {
+	switch d.Algorithm {
+	case AlgorithmMD5:
+		return md5.New(), nil
+	case AlgorithmSHA1:
+		return sha1.New(), nil
+	case AlgorithmSHA256:
+		return sha256.New(), nil
+	case AlgorithmCRC32C:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli)), nil
+	default:
+		return nil, fmt.Errorf("unsupported digest algorithm %q", d.Algorithm)
+	}
+}
+
+// verify compares the sum of h with the digest.
+func (d *Digest) verify(h hash.Hash) error {
+	actual := hex.EncodeToString(h.Sum(nil))
+	if actual != d.Encoded {
+		return &MismatchError{
+			Algorithm: d.Algorithm,
+			Expected:  d.Encoded,
+			Actual:    actual,
+		}
+	}
+
+	return nil
+}
+
+// Verify reads r to the end and verifies its digest.
+func (d *Digest) Verify(r io.Reader) error {
+	h, err := d.NewHash()
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+
+	return d.verify(h)
+}
+
+// VerifyFile verifies digest of file.
+func (d *Digest) VerifyFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return d.Verify(f)
+}
+
+// Reader hashes data while it is read, and returns error
+// of digest mismatch instead of io.EOF at the end of data.
+type Reader struct {
+	r      io.Reader
+	h      hash.Hash
+	digest *Digest
+}
+
+// NewReader returns Reader verifying data of r.
+func NewReader(r io.Reader, d *Digest) (*Reader, error) {
+	h, err := d.NewHash()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{r: r, h: h, digest: d}, nil
+}
+
+// Read implements io.Reader.
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	if errors.Is(err, io.EOF) {
+		if verifyErr := r.digest.verify(r.h); verifyErr != nil {
+			return n, verifyErr
+		}
+	}
+
+	return n, err
+}
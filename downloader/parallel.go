@@ -0,0 +1,216 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"urchinfs/config"
+	"urchinfs/dfstore"
+)
+
+const (
+	// DefaultPieceSize is the default size of a piece.
+	DefaultPieceSize = 16 << 20
+
+	// DefaultConcurrency is the default number of pieces downloaded concurrently.
+	DefaultConcurrency = 4
+)
+
+// PieceProgress reports a downloaded piece.
+type PieceProgress struct {
+	// Start is the offset of piece in object.
+	Start int64
+
+	// Length is the size of piece.
+	Length int64
+
+	// Completed is the number of bytes of object downloaded so far.
+	Completed int64
+
+	// Total is the size of object.
+	Total int64
+}
+
+// ParallelOptions is used to control parallel downloading.
+type ParallelOptions struct {
+	Options
+
+	// PieceSize is the size of a piece requested by one Range request.
+	PieceSize int64
+
+	// Concurrency is the number of workers downloading pieces.
+	Concurrency int
+
+	// OnPiece is called after every piece is written, calls are never concurrent.
+	OnPiece func(progress PieceProgress)
+}
+
+// withDefaults returns a copy of options with zero fields set to defaults.
+func (o *ParallelOptions) withDefaults() ParallelOptions {
+	var opts ParallelOptions
+	if o != nil {
+		opts = *o
+	}
+	opts.Options = opts.Options.withDefaults()
+
+	if opts.PieceSize <= 0 {
+		opts.PieceSize = DefaultPieceSize
+	}
+
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+
+	return opts
+}
+
+// ParallelDownload downloads object cached in peer to output by splitting it
+// into pieces and fetching them concurrently. Like Download, completed pieces
+// are kept in the sidecar state file, so the download can be resumed.
+func ParallelDownload(ctx context.Context, dfs dfstore.Dfstore, input *dfstore.GetUrfsInput, output string, opts *ParallelOptions) error {
+	o := opts.withDefaults()
+	return download(ctx, dfs, input, output, o.Options, func(ctx context.Context, f *os.File, s *state) error {
+		return fetchPieces(ctx, dfs, input, f, s, o)
+	})
+}
+
+// pieceResult is the result of fetching a piece, written is the number of
+// bytes written from the start of piece, it is less than the piece on error.
+type pieceResult struct {
+	piece   span
+	written int64
+	err     error
+}
+
+// fetchPieces fetches missing pieces by a pool of workers, pieces are queued
+// in a channel of config.DefaultPieceChanSize.
+func fetchPieces(ctx context.Context, dfs dfstore.Dfstore, input *dfstore.GetUrfsInput, f *os.File, s *state, o ParallelOptions) error {
+	pieces := splitSpans(s.missing(), o.PieceSize)
+	if len(pieces) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pieceCh := make(chan span, config.DefaultPieceChanSize)
+	go func() {
+		defer close(pieceCh)
+		for _, piece := range pieces {
+			select {
+			case pieceCh <- piece:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Results are always drained below, so workers never block on it forever.
+	resultCh := make(chan pieceResult, config.DefaultPieceChanSize)
+	var wg sync.WaitGroup
+	for i := 0; i < o.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for piece := range pieceCh {
+				written, err := fetchPiece(ctx, dfs, input, f, s, piece)
+				resultCh <- pieceResult{piece: piece, written: written, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultCh)
+	}()
+
+	completed := s.ContentLength
+	for _, sp := range s.missing() {
+		completed -= sp.End - sp.Start
+	}
+
+	var firstErr error
+	for result := range resultCh {
+		if result.err != nil {
+			if firstErr == nil {
+				firstErr = result.err
+				cancel()
+			}
+
+			// Bytes written before failure are recorded, so that the piece
+			// is resumed from there. They are synced with the part file
+			// before state is saved at the end of download.
+			s.complete(result.piece.Start, result.piece.Start+result.written)
+			continue
+		}
+
+		// Piece is recorded only after it is synced to disk.
+		if err := f.Sync(); err != nil {
+			if firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+			continue
+		}
+
+		s.complete(result.piece.Start, result.piece.End)
+		if err := s.save(); err != nil && firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+
+		completed += result.piece.End - result.piece.Start
+		if o.OnPiece != nil {
+			o.OnPiece(PieceProgress{
+				Start:     result.piece.Start,
+				Length:    result.piece.End - result.piece.Start,
+				Completed: completed,
+				Total:     s.ContentLength,
+			})
+		}
+	}
+
+	return firstErr
+}
+
+// fetchPiece downloads piece of object and writes it to f at the same offset,
+// it returns the number of bytes written.
+func fetchPiece(ctx context.Context, dfs dfstore.Dfstore, input *dfstore.GetUrfsInput, f *os.File, s *state, piece span) (int64, error) {
+	in := *input
+	in.Range = ""
+	in.ByteRange = &dfstore.Range{Start: piece.Start, Length: piece.End - piece.Start}
+
+	reader, meta, err := dfs.GetObjectWithContext(ctx, &in)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	if err := checkObject(s, meta, piece); err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(&offsetWriter{f: f, offset: piece.Start}, io.LimitReader(reader, piece.End-piece.Start))
+	if err != nil {
+		return n, err
+	}
+
+	if n != piece.End-piece.Start {
+		return n, io.ErrUnexpectedEOF
+	}
+
+	return n, nil
+}
+
+// splitSpans splits spans into pieces no larger than size.
+func splitSpans(spans []span, size int64) []span {
+	var pieces []span
+	for _, sp := range spans {
+		for start := sp.Start; start < sp.End; start += size {
+			pieces = append(pieces, span{Start: start, End: min64(start+size, sp.End)})
+		}
+	}
+
+	return pieces
+}
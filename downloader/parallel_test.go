@@ -0,0 +1,124 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+	"urchinfs/dfstore"
+)
+
+func testParallelOptions(pieceSize int64, concurrency int) *ParallelOptions {
+	return &ParallelOptions{
+		Options:     *testOptions(),
+		PieceSize:   pieceSize,
+		Concurrency: concurrency,
+	}
+}
+
+func TestParallelDownloadOutOfOrder(t *testing.T) {
+	peer := newTestPeer(t)
+	// Later pieces are responded first.
+	for i := int64(0); i < 4; i++ {
+		peer.delay[i*8] = time.Duration(4-i) * 50 * time.Millisecond
+	}
+	output := filepath.Join(t.TempDir(), "object")
+
+	var progress []PieceProgress
+	opts := testParallelOptions(8, 4)
+	opts.OnPiece = func(p PieceProgress) {
+		progress = append(progress, p)
+	}
+
+	if err := ParallelDownload(context.Background(), dfstore.New(""), peer.input(), output, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(progress) != 4 {
+		t.Fatalf("expected 4 pieces, got %+v", progress)
+	}
+
+	var starts []int64
+	for i, p := range progress {
+		starts = append(starts, p.Start)
+		if p.Length != 8 || p.Total != 32 || p.Completed != int64(i+1)*8 {
+			t.Errorf("unexpected progress %+v", p)
+		}
+	}
+
+	if sort.SliceIsSorted(starts, func(i, j int) bool { return starts[i] < starts[j] }) {
+		t.Errorf("expected pieces completed out of order, got %v", starts)
+	}
+
+	got := peer.requestedRanges()
+	sort.Strings(got)
+	checkRanges(t, got, "bytes=0-7", "bytes=16-23", "bytes=24-31", "bytes=8-15")
+	checkOutput(t, output)
+}
+
+func TestParallelDownloadResumesPartialPiece(t *testing.T) {
+	peer := newTestPeer(t)
+	peer.abortAfter[16] = 3
+	output := filepath.Join(t.TempDir(), "object")
+	dfs := dfstore.New("")
+
+	if err := ParallelDownload(context.Background(), dfs, peer.input(), output, testParallelOptions(16, 1)); err == nil {
+		t.Fatal("expected interrupted download to fail")
+	}
+
+	s := readState(t, output)
+	if len(s.Completed) != 1 || s.Completed[0] != (span{Start: 0, End: 19}) {
+		t.Fatalf("unexpected completed spans %+v", s.Completed)
+	}
+
+	if err := ParallelDownload(context.Background(), dfs, peer.input(), output, testParallelOptions(16, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	checkRanges(t, peer.requestedRanges(), "bytes=0-15", "bytes=16-31", "bytes=19-31")
+	checkOutput(t, output)
+}
+
+func TestParallelDownloadCanceled(t *testing.T) {
+	peer := newTestPeer(t)
+	// Later pieces are in flight when download is canceled.
+	peer.delay[16] = 200 * time.Millisecond
+	peer.delay[24] = 200 * time.Millisecond
+	output := filepath.Join(t.TempDir(), "object")
+	dfs := dfstore.New("")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	opts := testParallelOptions(8, 1)
+	opts.OnPiece = func(p PieceProgress) {
+		if p.Start == 8 {
+			cancel()
+		}
+	}
+
+	if err := ParallelDownload(ctx, dfs, peer.input(), output, opts); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	s := readState(t, output)
+	if len(s.Completed) != 1 || s.Completed[0].Start != 0 || s.Completed[0].End < 16 {
+		t.Fatalf("unexpected completed spans %+v", s.Completed)
+	}
+	resumed := s.Completed[0].End
+	requested := len(peer.requestedRanges())
+
+	if err := ParallelDownload(context.Background(), dfs, peer.input(), output, testParallelOptions(8, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing recorded in state is requested again.
+	for _, r := range peer.requestedRanges()[requested:] {
+		if start, _, err := parseRange(r, int64(len(testData))); err != nil || start < resumed {
+			t.Errorf("unexpected range %s after resuming from %d", r, resumed)
+		}
+	}
+	checkOutput(t, output)
+}
@@ -0,0 +1,318 @@
+// Package downloader downloads objects cached in peers to local files.
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+	"urchinfs/dfstore"
+	"urchinfs/digest"
+	pkgobjectstorage "urchinfs/objectstorage"
+)
+
+const (
+	// DefaultMaxAttempts is the default maximum number of download attempts.
+	DefaultMaxAttempts = 5
+
+	// DefaultRetryInterval is the default interval between download attempts.
+	DefaultRetryInterval = 3 * time.Second
+
+	// DefaultSaveInterval is the default number of bytes written between state saves.
+	DefaultSaveInterval = 64 << 20
+
+	// partSuffix is the suffix of the file being downloaded.
+	partSuffix = ".part"
+
+	// stateSuffix is the suffix of the sidecar state file.
+	stateSuffix = ".urfs-state"
+)
+
+var (
+	// ErrObjectChanged means the object is changed on peer during downloading.
+	ErrObjectChanged = errors.New("object changed during download")
+
+	// ErrRangeNotSupported means peer does not return the requested range.
+	ErrRangeNotSupported = errors.New("range not supported by peer")
+)
+
+// Options is used to control downloading.
+type Options struct {
+	// MaxAttempts is the maximum number of attempts, the download is
+	// resumed from the completed offsets on every attempt.
+	MaxAttempts int
+
+	// RetryInterval is the interval between attempts.
+	RetryInterval time.Duration
+
+	// SaveInterval is the number of bytes written between state saves.
+	SaveInterval int64
+
+	// VerifyDigest verifies downloaded data with the digest in object
+	// metadata before moving it to output, objects without digest are
+	// not verified. The download starts over if data mismatches.
+	VerifyDigest bool
+}
+
+// withDefaults returns a copy of options with zero fields set to defaults.
+func (o *Options) withDefaults() Options {
+	var opts Options
+	if o != nil {
+		opts = *o
+	}
+
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = DefaultRetryInterval
+	}
+
+	if opts.SaveInterval <= 0 {
+		opts.SaveInterval = DefaultSaveInterval
+	}
+
+	return opts
+}
+
+// Download downloads object cached in peer to output, the download survives
+// connection failures and process restarts by keeping completed offsets in
+// a sidecar state file, output is replaced atomically once all data is written.
+func Download(ctx context.Context, dfs dfstore.Dfstore, input *dfstore.GetUrfsInput, output string, opts *Options) error {
+	o := opts.withDefaults()
+	return download(ctx, dfs, input, output, o, func(ctx context.Context, f *os.File, s *state) error {
+		for _, sp := range s.missing() {
+			if err := fetchSpan(ctx, dfs, input, f, s, sp, o.SaveInterval); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// fetchFunc writes missing data of state to f.
+type fetchFunc func(ctx context.Context, f *os.File, s *state) error
+
+// download runs fetch with retries until all data of object is written,
+// then moves the part file to output.
+func download(ctx context.Context, dfs dfstore.Dfstore, input *dfstore.GetUrfsInput, output string, o Options, fetch fetchFunc) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+
+	var (
+		partPath  = output + partSuffix
+		statePath = output + stateSuffix
+		lastErr   error
+	)
+	for attempt := 1; attempt <= o.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			timer := time.NewTimer(o.RetryInterval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+
+		s, err := downloadOnce(ctx, dfs, input, partPath, statePath, fetch)
+		if err == nil && o.VerifyDigest {
+			err = verifyDigest(s, partPath)
+		}
+
+		if err == nil {
+			if err := os.Rename(partPath, output); err != nil {
+				return err
+			}
+
+			return s.remove()
+		}
+
+		if ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+		lastErr = err
+	}
+
+	return fmt.Errorf("download failed after %d attempts: %w", o.MaxAttempts, lastErr)
+}
+
+// downloadOnce checks object metadata, then writes missing data to the part file.
+func downloadOnce(ctx context.Context, dfs dfstore.Dfstore, input *dfstore.GetUrfsInput, partPath, statePath string, fetch fetchFunc) (*state, error) {
+	meta, err := dfs.GetUrfsMetadataWithContext(ctx, &dfstore.GetUrfsMetadataInput{
+		Endpoint:   input.Endpoint,
+		BucketName: input.BucketName,
+		ObjectKey:  input.ObjectKey,
+		DstPeer:    input.DstPeer,
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if meta.ContentLength < 0 {
+		return nil, errors.New("unknown content length of object")
+	}
+
+	s, err := loadState(statePath, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE
+	if s.isNew() {
+		flag |= os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(partPath, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// State is saved only after data is synced, so that it never
+	// records bytes which may be lost on crash.
+	fetchErr := fetch(ctx, f, s)
+	syncErr := f.Sync()
+	if fetchErr == nil {
+		fetchErr = syncErr
+	}
+
+	if err := f.Close(); err != nil && fetchErr == nil {
+		fetchErr = err
+	}
+
+	if errors.Is(fetchErr, ErrObjectChanged) {
+		// Start over on next attempt.
+		if err := s.remove(); err != nil {
+			return nil, err
+		}
+
+		return nil, fetchErr
+	}
+
+	if syncErr != nil {
+		return s, fetchErr
+	}
+
+	if err := s.save(); err != nil && fetchErr == nil {
+		fetchErr = err
+	}
+
+	return s, fetchErr
+}
+
+// verifyDigest verifies the part file with digest of state,
+// the state is removed on mismatch so that next attempt starts over.
+func verifyDigest(s *state, partPath string) error {
+	if s.Digest == "" {
+		return nil
+	}
+
+	d, err := digest.Parse(s.Digest)
+	if err != nil {
+		return err
+	}
+
+	if err := d.VerifyFile(partPath); err != nil {
+		if errors.Is(err, digest.ErrDigestMismatch) {
+			if err := s.remove(); err != nil {
+				return err
+			}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// fetchSpan downloads sp of object and writes it to f at the same offset,
+// the written bytes are recorded in state even if it fails halfway.
+func fetchSpan(ctx context.Context, dfs dfstore.Dfstore, input *dfstore.GetUrfsInput, f *os.File, s *state, sp span, saveInterval int64) error {
+	in := *input
+	in.Range = ""
+	in.ByteRange = &dfstore.Range{Start: sp.Start, Length: sp.End - sp.Start}
+
+	reader, meta, err := dfs.GetObjectWithContext(ctx, &in)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := checkObject(s, meta, sp); err != nil {
+		return err
+	}
+
+	w := &offsetWriter{f: f, offset: sp.Start}
+	for w.offset < sp.End {
+		start := w.offset
+		n, err := io.CopyN(w, reader, min64(saveInterval, sp.End-w.offset))
+		s.complete(start, start+n)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return io.ErrUnexpectedEOF
+			}
+
+			return err
+		}
+
+		if err := f.Sync(); err != nil {
+			return err
+		}
+
+		if err := s.save(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkObject checks the range response belongs to the object being downloaded,
+// ETag and Digest are only compared if both metadata and range response have them.
+func checkObject(s *state, meta *pkgobjectstorage.ObjectMetadata, sp span) error {
+	if s.ETag != "" && meta.ETag != "" && meta.ETag != s.ETag {
+		return fmt.Errorf("%w: etag %s, expected %s", ErrObjectChanged, meta.ETag, s.ETag)
+	}
+
+	if s.Digest != "" && meta.Digest != "" && meta.Digest != s.Digest {
+		return fmt.Errorf("%w: digest %s, expected %s", ErrObjectChanged, meta.Digest, s.Digest)
+	}
+
+	if meta.ContentLength >= 0 && meta.ContentLength != sp.End-sp.Start {
+		return fmt.Errorf("%w: %d bytes returned for range %d-%d", ErrRangeNotSupported, meta.ContentLength, sp.Start, sp.End-1)
+	}
+
+	return nil
+}
+
+// retryable returns whether the download may succeed on next attempt.
+func retryable(err error) bool {
+	return !errors.Is(err, dfstore.ErrNotFound) &&
+		!errors.Is(err, ErrRangeNotSupported) &&
+		!errors.Is(err, context.Canceled)
+}
+
+// offsetWriter writes to file at increasing offset.
+type offsetWriter struct {
+	f      *os.File
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.f.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
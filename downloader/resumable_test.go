@@ -0,0 +1,379 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"urchinfs/config"
+	"urchinfs/dfstore"
+)
+
+var testData = []byte("0123456789abcdefghijklmnopqrstuv")
+
+const objectPath = "/buckets/bucket.endpoint/objects/key"
+
+// testPeer serves object data with Range support, behaviors of GET can be
+// changed by its fields before requests are sent.
+type testPeer struct {
+	*httptest.Server
+
+	mu sync.Mutex
+
+	// headETag and getETag are ETag of HEAD and GET responses.
+	headETag string
+	getETag  string
+
+	// digest is X-Dragonfly-Object-Meta-Digest of responses.
+	digest string
+
+	// ignoreRange responds whole object to Range requests.
+	ignoreRange bool
+
+	// abortAfter aborts GET response of range starting at key after value bytes.
+	abortAfter map[int64]int
+
+	// delay delays GET response of range starting at key.
+	delay map[int64]time.Duration
+
+	// ranges is the Range header of every GET request.
+	ranges []string
+}
+
+func newTestPeer(t *testing.T) *testPeer {
+	p := &testPeer{
+		headETag:   `"etag"`,
+		getETag:    `"etag"`,
+		abortAfter: map[int64]int{},
+		delay:      map[int64]time.Duration{},
+	}
+	p.Server = httptest.NewServer(http.HandlerFunc(p.serveHTTP))
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+func (p *testPeer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != objectPath {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	p.mu.Lock()
+	etag := p.headETag
+	if r.Method == http.MethodGet {
+		etag = p.getETag
+		p.ranges = append(p.ranges, r.Header.Get("Range"))
+	}
+	digest, ignoreRange := p.digest, p.ignoreRange
+	p.mu.Unlock()
+
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if digest != "" {
+		w.Header().Set(config.HeaderDragonflyObjectMetaDigest, digest)
+	}
+
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Length", strconv.Itoa(len(testData)))
+		return
+	}
+
+	var start, end int64 = 0, int64(len(testData))
+	status := http.StatusOK
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" && !ignoreRange {
+		var err error
+		start, end, err = parseRange(rangeHeader, end)
+		if err != nil {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		status = http.StatusPartialContent
+	}
+
+	p.mu.Lock()
+	delay := p.delay[start]
+	abortAfter, abort := p.abortAfter[start]
+	delete(p.abortAfter, start)
+	p.mu.Unlock()
+
+	time.Sleep(delay)
+	w.Header().Set("Content-Length", strconv.FormatInt(end-start, 10))
+	w.WriteHeader(status)
+	if abort {
+		w.Write(testData[start : start+int64(abortAfter)])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+
+	w.Write(testData[start:end])
+}
+
+// parseRange parses range header of a single range, e.g. bytes=0-9 or bytes=10-.
+func parseRange(header string, size int64) (int64, int64, error) {
+	var start, last int64
+	if n, _ := fmt.Sscanf(header, "bytes=%d-%d", &start, &last); n == 2 {
+		return start, last + 1, nil
+	}
+
+	if n, _ := fmt.Sscanf(header, "bytes=%d-", &start); n == 1 {
+		return start, size, nil
+	}
+
+	return 0, 0, errors.New("invalid range")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// requestedRanges returns Range headers of GET requests so far.
+func (p *testPeer) requestedRanges() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string(nil), p.ranges...)
+}
+
+func (p *testPeer) input() *dfstore.GetUrfsInput {
+	return &dfstore.GetUrfsInput{
+		Endpoint:   "endpoint",
+		BucketName: "bucket",
+		ObjectKey:  "key",
+		DstPeer:    p.Listener.Addr().String(),
+	}
+}
+
+func testOptions() *Options {
+	return &Options{
+		MaxAttempts:   1,
+		RetryInterval: time.Millisecond,
+		SaveInterval:  4,
+	}
+}
+
+// writeState writes state file of output with completed spans.
+func writeState(t *testing.T, output, etag, digest string, completed ...span) {
+	data, err := json.Marshal(&state{
+		ETag:          etag,
+		Digest:        digest,
+		ContentLength: int64(len(testData)),
+		Completed:     completed,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(output+stateSuffix, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readState reads state file of output.
+func readState(t *testing.T, output string) *state {
+	data, err := os.ReadFile(output + stateSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &state{}
+	if err := json.Unmarshal(data, s); err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+// checkOutput checks output has test data and the part and state files are removed.
+func checkOutput(t *testing.T, output string) {
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, testData) {
+		t.Errorf("unexpected output %q", data)
+	}
+
+	for _, suffix := range []string{partSuffix, stateSuffix} {
+		if _, err := os.Stat(output + suffix); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected %s removed, got %v", suffix, err)
+		}
+	}
+}
+
+func checkRanges(t *testing.T, got []string, expect ...string) {
+	if len(got) != len(expect) {
+		t.Fatalf("expected ranges %q, got %q", expect, got)
+	}
+
+	for i := range got {
+		if got[i] != expect[i] {
+			t.Fatalf("expected ranges %q, got %q", expect, got)
+		}
+	}
+}
+
+func TestDownloadResumesFromState(t *testing.T) {
+	peer := newTestPeer(t)
+	peer.abortAfter[0] = 10
+	output := filepath.Join(t.TempDir(), "object")
+	dfs := dfstore.New("")
+
+	if err := Download(context.Background(), dfs, peer.input(), output, testOptions()); err == nil {
+		t.Fatal("expected interrupted download to fail")
+	}
+
+	s := readState(t, output)
+	if len(s.Completed) != 1 || s.Completed[0] != (span{Start: 0, End: 10}) {
+		t.Fatalf("unexpected completed spans %+v", s.Completed)
+	}
+
+	// A new download resumes from the state file.
+	if err := Download(context.Background(), dfs, peer.input(), output, testOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	checkRanges(t, peer.requestedRanges(), "bytes=0-31", "bytes=10-31")
+	checkOutput(t, output)
+}
+
+func TestDownloadRestartsOnObjectChange(t *testing.T) {
+	tests := []struct {
+		name   string
+		etag   string
+		digest string
+	}{
+		{name: "etag", etag: `"old"`},
+		{name: "digest", etag: `"etag"`, digest: "sha256:old"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peer := newTestPeer(t)
+			peer.digest = "sha256:" + sha256Hex(testData)
+			output := filepath.Join(t.TempDir(), "object")
+			writeState(t, output, tt.etag, tt.digest, span{Start: 0, End: 10})
+			if err := os.WriteFile(output+partSuffix, bytes.Repeat([]byte("x"), 10), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := Download(context.Background(), dfstore.New(""), peer.input(), output, testOptions()); err != nil {
+				t.Fatal(err)
+			}
+
+			checkRanges(t, peer.requestedRanges(), "bytes=0-31")
+			checkOutput(t, output)
+		})
+	}
+}
+
+func TestDownloadRestartsOnObjectChangeDuringDownload(t *testing.T) {
+	peer := newTestPeer(t)
+	peer.getETag = `"new"`
+	output := filepath.Join(t.TempDir(), "object")
+	writeState(t, output, `"etag"`, "", span{Start: 0, End: 10})
+
+	opts := testOptions()
+	opts.MaxAttempts = 2
+	err := Download(context.Background(), dfstore.New(""), peer.input(), output, opts)
+	if !errors.Is(err, ErrObjectChanged) {
+		t.Fatalf("expected ErrObjectChanged, got %v", err)
+	}
+
+	// State is removed, so that next download starts over.
+	if _, err := os.Stat(output + stateSuffix); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected state removed, got %v", err)
+	}
+
+	peer.mu.Lock()
+	peer.getETag = `"etag"`
+	peer.mu.Unlock()
+	if err := Download(context.Background(), dfstore.New(""), peer.input(), output, testOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	checkRanges(t, peer.requestedRanges(), "bytes=10-31", "bytes=0-31", "bytes=0-31")
+	checkOutput(t, output)
+}
+
+func TestDownloadETagOnlyInRangeResponse(t *testing.T) {
+	peer := newTestPeer(t)
+	peer.headETag = ""
+	peer.abortAfter[0] = 10
+	output := filepath.Join(t.TempDir(), "object")
+
+	opts := testOptions()
+	opts.MaxAttempts = 2
+	if err := Download(context.Background(), dfstore.New(""), peer.input(), output, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	checkRanges(t, peer.requestedRanges(), "bytes=0-31", "bytes=10-31")
+	checkOutput(t, output)
+}
+
+func TestDownloadRangeNotSupported(t *testing.T) {
+	peer := newTestPeer(t)
+	peer.ignoreRange = true
+	output := filepath.Join(t.TempDir(), "object")
+	writeState(t, output, `"etag"`, "", span{Start: 0, End: 10})
+
+	opts := testOptions()
+	opts.MaxAttempts = 3
+	err := Download(context.Background(), dfstore.New(""), peer.input(), output, opts)
+	if !errors.Is(err, ErrRangeNotSupported) {
+		t.Fatalf("expected ErrRangeNotSupported, got %v", err)
+	}
+
+	// It is not retried.
+	checkRanges(t, peer.requestedRanges(), "bytes=10-31")
+}
+
+func TestDownloadCorruptedState(t *testing.T) {
+	peer := newTestPeer(t)
+	output := filepath.Join(t.TempDir(), "object")
+	if err := os.WriteFile(output+stateSuffix, []byte("{corrupted"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(output+partSuffix, bytes.Repeat([]byte("x"), 64), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Download(context.Background(), dfstore.New(""), peer.input(), output, testOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	checkRanges(t, peer.requestedRanges(), "bytes=0-31")
+	checkOutput(t, output)
+}
+
+func TestDownloadVerifyDigest(t *testing.T) {
+	peer := newTestPeer(t)
+	peer.digest = "sha256:" + sha256Hex([]byte("other"))
+	output := filepath.Join(t.TempDir(), "object")
+
+	opts := testOptions()
+	opts.VerifyDigest = true
+	if err := Download(context.Background(), dfstore.New(""), peer.input(), output, opts); err == nil {
+		t.Fatal("expected digest mismatch")
+	}
+
+	if _, err := os.Stat(output); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected no output, got %v", err)
+	}
+}
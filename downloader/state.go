@@ -0,0 +1,160 @@
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	pkgobjectstorage "urchinfs/objectstorage"
+)
+
+// span is a byte range [Start, End) of object.
+type span struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// state is the sidecar state of an unfinished download.
+type state struct {
+	mu sync.Mutex
+
+	// path is the path of state file.
+	path string
+
+	// ETag, Digest and ContentLength identify the object being downloaded.
+	ETag          string `json:"etag"`
+	Digest        string `json:"digest"`
+	ContentLength int64  `json:"contentLength"`
+
+	// Completed is sorted and merged byte ranges written to the part file.
+	Completed []span `json:"completed"`
+}
+
+// loadState loads state from path, it returns a new state if the file
+// does not exist or the object is changed since the state was saved.
+func loadState(path string, meta *pkgobjectstorage.ObjectMetadata) (*state, error) {
+	s := &state{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if err == nil {
+		if err := json.Unmarshal(data, s); err != nil {
+			// Corrupted state is restarted rather than failing the download.
+			s = &state{path: path}
+		}
+	}
+
+	if !s.matches(meta) {
+		s = &state{
+			path:          path,
+			ETag:          meta.ETag,
+			Digest:        meta.Digest,
+			ContentLength: meta.ContentLength,
+		}
+	}
+
+	return s, nil
+}
+
+// matches returns whether the state belongs to the object of meta.
+func (s *state) matches(meta *pkgobjectstorage.ObjectMetadata) bool {
+	return s.ETag == meta.ETag && s.Digest == meta.Digest && s.ContentLength == meta.ContentLength
+}
+
+// isNew returns whether nothing has been downloaded.
+func (s *state) isNew() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.Completed) == 0
+}
+
+// complete marks [start, end) as downloaded.
+func (s *state) complete(start, end int64) {
+	if start >= end {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	spans := append(s.Completed, span{Start: start, End: end})
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+
+	merged := spans[:1]
+	for _, sp := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if sp.Start <= last.End {
+			if sp.End > last.End {
+				last.End = sp.End
+			}
+			continue
+		}
+
+		merged = append(merged, sp)
+	}
+	s.Completed = merged
+}
+
+// missing returns byte ranges not downloaded yet.
+func (s *state) missing() []span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		spans  []span
+		offset int64
+	)
+	for _, sp := range s.Completed {
+		if sp.Start > offset {
+			spans = append(spans, span{Start: offset, End: sp.Start})
+		}
+		offset = sp.End
+	}
+
+	if offset < s.ContentLength {
+		spans = append(spans, span{Start: offset, End: s.ContentLength})
+	}
+
+	return spans
+}
+
+// save writes state to its path atomically.
+func (s *state) save() error {
+	s.mu.Lock()
+	data, err := json.Marshal(s)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path)
+}
+
+// remove deletes the state file.
+func (s *state) remove() error {
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+// Package idgen computes task IDs the same way as peers, so that tasks can be
+// correlated and looked up without asking peer.
+package idgen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+)
+
+// filterSeparator separates query params in filter.
+const filterSeparator = "&"
+
+// URLMeta is the metadata of url which takes part in task ID.
+type URLMeta struct {
+	// Digest is the digest of data, in format of algorithm:hex.
+	Digest string
+
+	// Tag divides tasks of the same url into different P2P overlays,
+	// it is the value of X-Dragonfly-Tag.
+	Tag string
+
+	// Range is the range of data as peer receives it, e.g. bytes=0-1023.
+	Range string
+
+	// Filter is the query params excluded from url, it is separated by & character.
+	Filter string
+}
+
+// TaskID returns task ID of url, query params in filter are excluded
+// from url, tag and rng are optional.
+func TaskID(url, filter, tag, rng string) string {
+	return TaskIDFromMeta(url, &URLMeta{
+		Filter: filter,
+		Tag:    tag,
+		Range:  rng,
+	})
+}
+
+// TaskIDFromMeta returns task ID of url with meta, meta is optional.
+//
+// Task ID is the hex encoded SHA-256 of url without filtered query params,
+// followed by the non-empty fields of Digest, Range and Tag. Application is
+// not part of task ID, different applications share the task of url.
+func TaskIDFromMeta(url string, meta *URLMeta) string {
+	if meta == nil {
+		return sha256FromStrings(url)
+	}
+
+	// Peer hashes empty url if url can not be parsed.
+	u, err := FilterURLParam(url, parseFilters(meta.Filter))
+	if err != nil {
+		u = ""
+	}
+
+	data := []string{u}
+	if meta.Digest != "" {
+		data = append(data, meta.Digest)
+	}
+
+	if meta.Range != "" {
+		data = append(data, meta.Range)
+	}
+
+	if meta.Tag != "" {
+		data = append(data, meta.Tag)
+	}
+
+	return sha256FromStrings(data...)
+}
+
+// FilterURLParam removes query params in filters from url, filters match
+// keys exactly and the remaining params are sorted by key, url is returned
+// unchanged without filters.
+// E.g. http://a.b.com/locate?key1=value1&key2=value2&key3=value3 with
+// filter key2 is http://a.b.com/locate?key1=value1&key3=value3.
+func FilterURLParam(rawURL string, filters []string) (string, error) {
+	if len(filters) == 0 {
+		return rawURL, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	hidden := make(map[string]struct{}, len(filters))
+	for _, filter := range filters {
+		hidden[filter] = struct{}{}
+	}
+
+	values := make(url.Values)
+	for k, v := range u.Query() {
+		if _, ok := hidden[k]; !ok {
+			values[k] = v
+		}
+	}
+
+	u.RawQuery = values.Encode()
+	return u.String(), nil
+}
+
+// parseFilters splits filter into query param names.
+func parseFilters(filter string) []string {
+	if strings.TrimSpace(filter) == "" {
+		return nil
+	}
+
+	return strings.Split(filter, filterSeparator)
+}
+
+// sha256FromStrings returns hex encoded SHA-256 of data concatenated.
+func sha256FromStrings(data ...string) string {
+	h := sha256.New()
+	for _, s := range data {
+		h.Write([]byte(s))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,147 @@
+package idgen
+
+import (
+	"testing"
+)
+
+// Golden task IDs are the vectors of task_id_test.go in Dragonfly pkg/idgen,
+// whose algorithm peers use to compute task IDs.
+func TestTaskIDFromMeta(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		meta   *URLMeta
+		expect string
+	}{
+		{
+			name:   "url",
+			url:    "https://example.com",
+			meta:   nil,
+			expect: "100680ad546ce6a577f42f52df33b4cfdca756859e664b8d7de329b150d09ce9",
+		},
+		{
+			name: "range and digest",
+			url:  "https://example.com",
+			meta: &URLMeta{
+				Range:  "foo",
+				Digest: "bar",
+			},
+			expect: "aeee0e0a2a0c75130582641353c539aaf9011a0088b31347f7588e70e449a3e0",
+		},
+		{
+			name: "digest",
+			url:  "https://example.com",
+			meta: &URLMeta{
+				Digest: "bar",
+			},
+			expect: "63dee2822037636b0109876b58e95692233840753a882afa69b9b5ee82a6c57d",
+		},
+		{
+			name: "filter",
+			url:  "https://example.com?foo=foo&bar=bar",
+			meta: &URLMeta{
+				Tag:    "foo",
+				Filter: "foo&bar",
+			},
+			expect: "2773851c628744fb7933003195db436ce397c1722920696c4274ff804d86920b",
+		},
+		{
+			name: "tag",
+			url:  "https://example.com",
+			meta: &URLMeta{
+				Tag: "foo",
+			},
+			expect: "2773851c628744fb7933003195db436ce397c1722920696c4274ff804d86920b",
+		},
+		{
+			name: "filter matches keys exactly",
+			url:  "https://example.com?Foo=foo",
+			meta: &URLMeta{
+				Filter: "foo",
+			},
+			expect: sha256FromStrings("https://example.com?Foo=foo"),
+		},
+		{
+			name: "invalid url",
+			url:  ":error_url",
+			meta: &URLMeta{
+				Filter: "foo",
+			},
+			expect: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TaskIDFromMeta(tt.url, tt.meta); got != tt.expect {
+				t.Errorf("TaskIDFromMeta(%q) = %s, expected %s", tt.url, got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestTaskID(t *testing.T) {
+	if got := TaskID("https://example.com?foo=foo&bar=bar", "foo&bar", "foo", ""); got != "2773851c628744fb7933003195db436ce397c1722920696c4274ff804d86920b" {
+		t.Errorf("unexpected task id %s", got)
+	}
+}
+
+func TestFilterURLParam(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		filters []string
+		expect  string
+		wantErr bool
+	}{
+		{
+			name:    "filter keys",
+			url:     "http://www.xx.yy/path?u=f&x=y&m=z&x=s#size",
+			filters: []string{"x", "m"},
+			expect:  "http://www.xx.yy/path?u=f#size",
+		},
+		{
+			name:    "keep repeated keys",
+			url:     "http://www.xx.yy/path?u=f&x=y&m=z&x=s#size",
+			filters: []string{"m"},
+			expect:  "http://www.xx.yy/path?u=f&x=y&x=s#size",
+		},
+		{
+			name:    "case sensitive",
+			url:     "http://www.xx.yy/path?M=z&u=f",
+			filters: []string{"m"},
+			expect:  "http://www.xx.yy/path?M=z&u=f",
+		},
+		{
+			name:   "no filters",
+			url:    "http://www.xx.yy/path?x=y&u=f",
+			expect: "http://www.xx.yy/path?x=y&u=f",
+		},
+		{
+			name:    "invalid url",
+			url:     ":error_url",
+			filters: []string{"m"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FilterURLParam(tt.url, tt.filters)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %s", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.expect {
+				t.Errorf("FilterURLParam(%q) = %s, expected %s", tt.url, got, tt.expect)
+			}
+		})
+	}
+}
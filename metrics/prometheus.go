@@ -0,0 +1,129 @@
+// Package metrics exports metrics of requests to peers to Prometheus.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+	"time"
+	"urchinfs/dfstore"
+)
+
+// DefaultNamespace is the default namespace of metrics.
+const DefaultNamespace = "urchin"
+
+// Prometheus implements dfstore.Metrics and prometheus.Collector, register it
+// to a registry and pass it to urchin.WithMetrics or dfstore.WithMetrics.
+type Prometheus struct {
+	requests       *prometheus.CounterVec
+	duration       *prometheus.HistogramVec
+	inFlight       *prometheus.GaugeVec
+	scheduledBytes *prometheus.CounterVec
+}
+
+var _ dfstore.Metrics = (*Prometheus)(nil)
+
+var _ prometheus.Collector = (*Prometheus)(nil)
+
+// PrometheusOption is a functional option for configuring the Prometheus metrics.
+type PrometheusOption func(o *prometheusOptions)
+
+type prometheusOptions struct {
+	namespace string
+	buckets   []float64
+}
+
+// WithNamespace set namespace of metrics, default is DefaultNamespace.
+func WithNamespace(namespace string) PrometheusOption {
+	return func(o *prometheusOptions) {
+		o.namespace = namespace
+	}
+}
+
+// WithBuckets set buckets of request duration histogram in seconds,
+// default is prometheus.DefBuckets.
+func WithBuckets(buckets []float64) PrometheusOption {
+	return func(o *prometheusOptions) {
+		o.buckets = buckets
+	}
+}
+
+// NewPrometheus returns Prometheus metrics, which are:
+//
+//	urchin_requests_total{operation,status,peer}
+//	urchin_request_duration_seconds{operation,peer}
+//	urchin_requests_in_flight{operation,peer}
+//	urchin_scheduled_bytes_total{peer}
+//
+// status is the response status code, or "error" if no response is received.
+func NewPrometheus(options ...PrometheusOption) *Prometheus {
+	o := &prometheusOptions{
+		namespace: DefaultNamespace,
+		buckets:   prometheus.DefBuckets,
+	}
+
+	for _, opt := range options {
+		opt(o)
+	}
+
+	return &Prometheus{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Name:      "requests_total",
+			Help:      "Total number of requests to peers.",
+		}, []string{"operation", "status", "peer"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Duration of requests to peers until response header is received.",
+			Buckets:   o.buckets,
+		}, []string{"operation", "peer"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: o.namespace,
+			Name:      "requests_in_flight",
+			Help:      "Number of requests to peers waiting for response.",
+		}, []string{"operation", "peer"}),
+		scheduledBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Name:      "scheduled_bytes_total",
+			Help:      "Total bytes of schedule tasks accepted by peers.",
+		}, []string{"peer"}),
+	}
+}
+
+// RequestStarted implements dfstore.Metrics.
+func (p *Prometheus) RequestStarted(operation, peer string) {
+	p.inFlight.WithLabelValues(operation, peer).Inc()
+}
+
+// RequestFinished implements dfstore.Metrics.
+func (p *Prometheus) RequestFinished(operation, peer string, statusCode int, duration time.Duration) {
+	p.inFlight.WithLabelValues(operation, peer).Dec()
+
+	status := "error"
+	if statusCode != 0 {
+		status = strconv.Itoa(statusCode)
+	}
+	p.requests.WithLabelValues(operation, status, peer).Inc()
+	p.duration.WithLabelValues(operation, peer).Observe(duration.Seconds())
+}
+
+// BytesScheduled implements dfstore.Metrics.
+func (p *Prometheus) BytesScheduled(peer string, bytes int64) {
+	p.scheduledBytes.WithLabelValues(peer).Add(float64(bytes))
+}
+
+// Describe implements prometheus.Collector.
+func (p *Prometheus) Describe(ch chan<- *prometheus.Desc) {
+	p.requests.Describe(ch)
+	p.duration.Describe(ch)
+	p.inFlight.Describe(ch)
+	p.scheduledBytes.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (p *Prometheus) Collect(ch chan<- prometheus.Metric) {
+	p.requests.Collect(ch)
+	p.duration.Collect(ch)
+	p.inFlight.Collect(ch)
+	p.scheduledBytes.Collect(ch)
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"urchinfs/urchin"
+)
+
+// newPeer returns peer serving metadata and schedule task of a 5 bytes object,
+// it records the in-flight requests of schedule task seen while serving it.
+func newPeer(t *testing.T, p *Prometheus, inFlight *float64) *httptest.Server {
+	var peer *httptest.Server
+	peer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead && r.URL.Path == "/buckets/bucket.endpoint/objects/key":
+			w.Header().Set("Content-Length", "5")
+		case r.Method == http.MethodPost && r.URL.Path == "/buckets/bucket.endpoint/cache_object/key":
+			*inFlight = testutil.ToFloat64(p.inFlight.WithLabelValues("cache_object", peer.Listener.Addr().String()))
+			io.WriteString(w, `{"Content-Length": "5", "StatusCode": 0, "TaskID": "task"}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(peer.Close)
+
+	return peer
+}
+
+func TestPrometheus(t *testing.T) {
+	p := NewPrometheus()
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(p); err != nil {
+		t.Fatal(err)
+	}
+
+	var inFlight float64
+	peer := newPeer(t, p, &inFlight)
+	host := peer.Listener.Addr().String()
+
+	ufs, err := urchin.New(urchin.WithMetrics(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ufs.ScheduleDataToPeerByKeyWithContext(context.Background(), "endpoint", "bucket", "key", host, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, operation := range []string{"head_object", "cache_object"} {
+		if got := testutil.ToFloat64(p.requests.WithLabelValues(operation, "200", host)); got != 1 {
+			t.Errorf("expected 1 %s request, got %v", operation, got)
+		}
+
+		if got := testutil.ToFloat64(p.inFlight.WithLabelValues(operation, host)); got != 0 {
+			t.Errorf("expected no %s request in flight, got %v", operation, got)
+		}
+	}
+
+	if inFlight != 1 {
+		t.Errorf("expected 1 cache_object request in flight while serving, got %v", inFlight)
+	}
+
+	if got := testutil.ToFloat64(p.scheduledBytes.WithLabelValues(host)); got != 5 {
+		t.Errorf("expected 5 scheduled bytes, got %v", got)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var observations uint64
+	for _, family := range families {
+		if family.GetName() != "urchin_request_duration_seconds" {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			observations += metric.GetHistogram().GetSampleCount()
+		}
+	}
+
+	if observations != 2 {
+		t.Errorf("expected 2 request durations observed, got %d", observations)
+	}
+
+	expected := `
+# HELP urchin_scheduled_bytes_total Total bytes of schedule tasks accepted by peers.
+# TYPE urchin_scheduled_bytes_total counter
+urchin_scheduled_bytes_total{peer="` + host + `"} 5
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "urchin_scheduled_bytes_total"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,438 @@
+// Package peerpool keeps a pool of peers, probes their health and picks
+// the best one, so that callers do not depend on a single hardcoded peer.
+package peerpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"urchinfs/config"
+	"urchinfs/dfstore"
+)
+
+const (
+	// DefaultHealthPath is the default path of peer health endpoint,
+	// which is served by dfdaemon on config.DefaultHealthyStartPort.
+	DefaultHealthPath = "/server/ping"
+
+	// DefaultProbeInterval is the default interval between health probes.
+	DefaultProbeInterval = 10 * time.Second
+
+	// DefaultProbeTimeout is the default timeout of a health probe.
+	DefaultProbeTimeout = 2 * time.Second
+
+	// DefaultFailureThreshold is the default number of consecutive
+	// failures after which a peer is unhealthy.
+	DefaultFailureThreshold = 3
+
+	// latencyWeight is the weight of the latest probe in the moving average of latency.
+	latencyWeight = 0.3
+)
+
+// ErrNoHealthyPeer means no peer in pool is healthy.
+var ErrNoHealthyPeer = errors.New("no healthy peer")
+
+// Logger is the interface used for printing debug messages, *log.Logger satisfies it.
+type Logger = dfstore.Logger
+
+// PeerStatus is the health of a peer.
+type PeerStatus struct {
+	// Host is the peer host passed as dstPeer, e.g. 127.0.0.1:65004.
+	Host string
+
+	// Healthy is false after consecutive failures reach the failure threshold,
+	// peers are healthy until they are probed.
+	Healthy bool
+
+	// Latency is the moving average of probe latency.
+	Latency time.Duration
+
+	// Failures is the number of consecutive failures.
+	Failures int
+
+	// LastProbe is the time of the latest probe.
+	LastProbe time.Time
+
+	// LastError is the error of the latest failed probe or request.
+	LastError error
+}
+
+// Pool is a pool of peers, it is safe for concurrent use.
+type Pool struct {
+	mu    sync.RWMutex
+	peers []*PeerStatus
+
+	httpClient       *http.Client
+	interval         time.Duration
+	timeout          time.Duration
+	healthPort       int
+	healthPath       string
+	failureThreshold int
+	logger           Logger
+
+	startOnce sync.Once
+	closeOnce sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// Option is a functional option for configuring the pool.
+type Option func(p *Pool)
+
+// WithHTTPClient set http client used to probe peers.
+func WithHTTPClient(client *http.Client) Option {
+	return func(p *Pool) {
+		p.httpClient = client
+	}
+}
+
+// WithProbeInterval set interval between health probes.
+func WithProbeInterval(interval time.Duration) Option {
+	return func(p *Pool) {
+		p.interval = interval
+	}
+}
+
+// WithProbeTimeout set timeout of a health probe.
+func WithProbeTimeout(timeout time.Duration) Option {
+	return func(p *Pool) {
+		p.timeout = timeout
+	}
+}
+
+// WithHealthPort set port of peer health endpoint, default is config.DefaultHealthyStartPort.
+func WithHealthPort(port int) Option {
+	return func(p *Pool) {
+		p.healthPort = port
+	}
+}
+
+// WithHealthPath set path of peer health endpoint, default is DefaultHealthPath.
+func WithHealthPath(path string) Option {
+	return func(p *Pool) {
+		p.healthPath = path
+	}
+}
+
+// WithFailureThreshold set number of consecutive failures after which a peer is unhealthy.
+func WithFailureThreshold(threshold int) Option {
+	return func(p *Pool) {
+		p.failureThreshold = threshold
+	}
+}
+
+// WithLogger set logger for pool.
+func WithLogger(logger Logger) Option {
+	return func(p *Pool) {
+		p.logger = logger
+	}
+}
+
+// New returns pool of static peer hosts, duplicated hosts are ignored.
+// Call Start to probe peers in background.
+func New(hosts []string, options ...Option) (*Pool, error) {
+	p := &Pool{
+		httpClient:       http.DefaultClient,
+		interval:         DefaultProbeInterval,
+		timeout:          DefaultProbeTimeout,
+		healthPort:       config.DefaultHealthyStartPort,
+		healthPath:       DefaultHealthPath,
+		failureThreshold: DefaultFailureThreshold,
+		stop:             make(chan struct{}),
+		done:             make(chan struct{}),
+	}
+
+	for _, opt := range options {
+		opt(p)
+	}
+
+	seen := make(map[string]bool)
+	for _, host := range hosts {
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		p.peers = append(p.peers, &PeerStatus{Host: host, Healthy: true})
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// validate validates pool options.
+func (p *Pool) validate() error {
+	if len(p.peers) == 0 {
+		return errors.New("peer pool requires peers")
+	}
+
+	if p.httpClient == nil {
+		return errors.New("peer pool requires http client")
+	}
+
+	if p.interval <= 0 {
+		return fmt.Errorf("invalid probe interval %s", p.interval)
+	}
+
+	if p.timeout <= 0 {
+		return fmt.Errorf("invalid probe timeout %s", p.timeout)
+	}
+
+	if p.healthPort <= 0 || p.healthPort > config.DefaultEndPort {
+		return fmt.Errorf("invalid health port %d", p.healthPort)
+	}
+
+	if !strings.HasPrefix(p.healthPath, "/") {
+		return fmt.Errorf("invalid health path %q", p.healthPath)
+	}
+
+	if p.failureThreshold <= 0 {
+		return fmt.Errorf("invalid failure threshold %d", p.failureThreshold)
+	}
+
+	return nil
+}
+
+// Start probes peers immediately and then on every interval until Close is called.
+func (p *Pool) Start() {
+	p.startOnce.Do(func() {
+		go p.run()
+	})
+}
+
+func (p *Pool) run() {
+	defer close(p.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-p.stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.Probe(ctx)
+
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close stops background probes started by Start.
+func (p *Pool) Close() {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+	})
+
+	started := true
+	p.startOnce.Do(func() {
+		started = false
+	})
+
+	if started {
+		<-p.done
+	}
+}
+
+// Probe probes all peers concurrently and waits for the results.
+func (p *Pool) Probe(ctx context.Context) {
+	p.mu.RLock()
+	hosts := make([]string, 0, len(p.peers))
+	for _, peer := range p.peers {
+		hosts = append(hosts, peer.Host)
+	}
+	p.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			start := time.Now()
+			err := p.probe(ctx, host)
+			p.record(host, time.Since(start), start, err)
+		}(host)
+	}
+	wg.Wait()
+}
+
+// probe requests health endpoint of peer.
+func (p *Pool) probe(ctx context.Context, host string) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.healthURL(host), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("health check status %s", resp.Status)
+	}
+
+	return nil
+}
+
+// healthURL returns health endpoint of peer, which listens on the health port of peer ip.
+func (p *Pool) healthURL(host string) string {
+	ip, _, err := net.SplitHostPort(host)
+	if err != nil {
+		ip = host
+	}
+
+	return "http://" + net.JoinHostPort(ip, strconv.Itoa(p.healthPort)) + p.healthPath
+}
+
+// record updates status of peer with result of probe.
+func (p *Pool) record(host string, latency time.Duration, at time.Time, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	peer := p.lookup(host)
+	if peer == nil {
+		return
+	}
+	peer.LastProbe = at
+
+	if err != nil {
+		p.fail(peer, err)
+		return
+	}
+
+	if peer.Latency == 0 {
+		peer.Latency = latency
+	} else {
+		peer.Latency = time.Duration(latencyWeight*float64(latency) + (1-latencyWeight)*float64(peer.Latency))
+	}
+
+	if !peer.Healthy {
+		p.logf("peer %s is healthy", host)
+	}
+	peer.Healthy = true
+	peer.Failures = 0
+	peer.LastError = nil
+}
+
+// fail counts a failure of peer, p.mu must be held.
+func (p *Pool) fail(peer *PeerStatus, err error) {
+	peer.Failures++
+	peer.LastError = err
+	if peer.Healthy && peer.Failures >= p.failureThreshold {
+		peer.Healthy = false
+		p.logf("peer %s is unhealthy: %v", peer.Host, err)
+	}
+}
+
+// ReportFailure counts a failed request to peer, peer becomes unhealthy
+// after consecutive failures reach the failure threshold, and healthy
+// again once a probe succeeds.
+func (p *Pool) ReportFailure(host string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if peer := p.lookup(host); peer != nil {
+		p.fail(peer, err)
+	}
+}
+
+// PickPeer returns the healthy peer with the lowest latency, peers in exclude are skipped.
+// Peers without successful probes have no latency, they are picked in order only
+// if no measured peer is healthy.
+func (p *Pool) PickPeer(exclude ...string) (string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var best *PeerStatus
+	for _, peer := range p.peers {
+		if !peer.Healthy || contains(exclude, peer.Host) {
+			continue
+		}
+
+		if best == nil || faster(peer, best) {
+			best = peer
+		}
+	}
+
+	if best == nil {
+		return "", ErrNoHealthyPeer
+	}
+
+	return best.Host, nil
+}
+
+// Peers returns status of all peers.
+func (p *Pool) Peers() []PeerStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	peers := make([]PeerStatus, 0, len(p.peers))
+	for _, peer := range p.peers {
+		peers = append(peers, *peer)
+	}
+
+	return peers
+}
+
+// Len returns number of peers.
+func (p *Pool) Len() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return len(p.peers)
+}
+
+// lookup returns status of peer, p.mu must be held.
+func (p *Pool) lookup(host string) *PeerStatus {
+	for _, peer := range p.peers {
+		if peer.Host == host {
+			return peer
+		}
+	}
+
+	return nil
+}
+
+// logf prints debug message if logger is set.
+func (p *Pool) logf(format string, v ...interface{}) {
+	if p.logger != nil {
+		p.logger.Printf(format, v...)
+	}
+}
+
+// faster returns whether a has lower latency than b, unmeasured latency is the highest.
+func faster(a, b *PeerStatus) bool {
+	if a.Latency == 0 || b.Latency == 0 {
+		return a.Latency != 0 && b.Latency == 0
+	}
+
+	return a.Latency < b.Latency
+}
+
+func contains(s []string, target string) bool {
+	for _, v := range s {
+		if v == target {
+			return true
+		}
+	}
+
+	return false
+}
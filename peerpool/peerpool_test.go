@@ -0,0 +1,232 @@
+package peerpool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testHealth responds health probes of peer ips, probes of an ip fail if its status is not 2xx.
+type testHealth struct {
+	mu     sync.Mutex
+	status map[string]int
+	delay  map[string]time.Duration
+	paths  []string
+}
+
+func newTestHealth() *testHealth {
+	return &testHealth{
+		status: map[string]int{},
+		delay:  map[string]time.Duration{},
+	}
+}
+
+func (h *testHealth) RoundTrip(req *http.Request) (*http.Response, error) {
+	h.mu.Lock()
+	status, ok := h.status[req.URL.Hostname()]
+	delay := h.delay[req.URL.Hostname()]
+	h.paths = append(h.paths, req.URL.Path)
+	h.mu.Unlock()
+
+	if !ok {
+		return nil, errors.New("connection refused")
+	}
+	time.Sleep(delay)
+
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func (h *testHealth) setStatus(ip string, status int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.status[ip] = status
+}
+
+func newTestPool(t *testing.T, h *testHealth, hosts []string, options ...Option) *Pool {
+	options = append([]Option{WithHTTPClient(&http.Client{Transport: h})}, options...)
+	p, err := New(hosts, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func peerStatus(t *testing.T, p *Pool, host string) PeerStatus {
+	for _, peer := range p.Peers() {
+		if peer.Host == host {
+			return peer
+		}
+	}
+
+	t.Fatalf("peer %s not found", host)
+	return PeerStatus{}
+}
+
+func TestNew(t *testing.T) {
+	p, err := New([]string{"10.0.0.1:65004", "", "10.0.0.1:65004", "10.0.0.2:65004"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Len() != 2 {
+		t.Errorf("expected duplicated hosts ignored, got %+v", p.Peers())
+	}
+
+	if _, err := New(nil); err == nil {
+		t.Error("expected error of empty pool")
+	}
+
+	if _, err := New([]string{"10.0.0.1:65004"}, WithHealthPath("server/ping")); err == nil {
+		t.Error("expected error of invalid health path")
+	}
+}
+
+func TestHealthURL(t *testing.T) {
+	h := newTestHealth()
+	h.setStatus("10.0.0.1", http.StatusOK)
+	p := newTestPool(t, h, []string{"10.0.0.1:65004"})
+	p.Probe(context.Background())
+
+	if url := p.healthURL("10.0.0.1:65004"); url != "http://10.0.0.1:40901/server/ping" {
+		t.Errorf("unexpected health url %s", url)
+	}
+
+	p = newTestPool(t, h, []string{"10.0.0.1:65004"}, WithHealthPort(8080), WithHealthPath("/healthy"))
+	p.Probe(context.Background())
+
+	if url := p.healthURL("10.0.0.1:65004"); url != "http://10.0.0.1:8080/healthy" {
+		t.Errorf("unexpected health url %s", url)
+	}
+
+	if len(h.paths) != 2 || h.paths[0] != "/server/ping" || h.paths[1] != "/healthy" {
+		t.Errorf("unexpected probed paths %q", h.paths)
+	}
+}
+
+func TestFailureThreshold(t *testing.T) {
+	h := newTestHealth()
+	h.setStatus("10.0.0.1", http.StatusServiceUnavailable)
+	host := "10.0.0.1:65004"
+	p := newTestPool(t, h, []string{host}, WithFailureThreshold(2))
+
+	p.Probe(context.Background())
+	if peer := peerStatus(t, p, host); !peer.Healthy || peer.Failures != 1 || peer.LastError == nil {
+		t.Fatalf("expected healthy peer below threshold, got %+v", peer)
+	}
+
+	p.Probe(context.Background())
+	if peer := peerStatus(t, p, host); peer.Healthy || peer.Failures != 2 {
+		t.Fatalf("expected unhealthy peer at threshold, got %+v", peer)
+	}
+
+	if _, err := p.PickPeer(); !errors.Is(err, ErrNoHealthyPeer) {
+		t.Fatalf("expected ErrNoHealthyPeer, got %v", err)
+	}
+}
+
+func TestReportFailure(t *testing.T) {
+	host := "10.0.0.1:65004"
+	p := newTestPool(t, newTestHealth(), []string{host}, WithFailureThreshold(2))
+
+	p.ReportFailure(host, errors.New("peer unavailable"))
+	p.ReportFailure("10.0.0.9:65004", errors.New("unknown peer"))
+	if peer := peerStatus(t, p, host); !peer.Healthy || peer.Failures != 1 {
+		t.Fatalf("expected healthy peer below threshold, got %+v", peer)
+	}
+
+	p.ReportFailure(host, errors.New("peer unavailable"))
+	if peer := peerStatus(t, p, host); peer.Healthy {
+		t.Fatalf("expected unhealthy peer at threshold, got %+v", peer)
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	h := newTestHealth()
+	host := "10.0.0.1:65004"
+	p := newTestPool(t, h, []string{host}, WithFailureThreshold(1))
+
+	p.Probe(context.Background())
+	if peer := peerStatus(t, p, host); peer.Healthy {
+		t.Fatalf("expected unhealthy peer, got %+v", peer)
+	}
+
+	h.setStatus("10.0.0.1", http.StatusOK)
+	p.Probe(context.Background())
+	peer := peerStatus(t, p, host)
+	if !peer.Healthy || peer.Failures != 0 || peer.LastError != nil || peer.Latency == 0 {
+		t.Fatalf("expected peer recovered, got %+v", peer)
+	}
+
+	if got, err := p.PickPeer(); err != nil || got != host {
+		t.Fatalf("expected %s, got %s, %v", host, got, err)
+	}
+}
+
+func TestPickPeer(t *testing.T) {
+	h := newTestHealth()
+	h.setStatus("10.0.0.1", http.StatusOK)
+	h.setStatus("10.0.0.2", http.StatusOK)
+	h.delay["10.0.0.1"] = 50 * time.Millisecond
+	hosts := []string{"10.0.0.1:65004", "10.0.0.2:65004", "10.0.0.3:65004"}
+	p := newTestPool(t, h, hosts, WithFailureThreshold(3))
+
+	// Peers are picked in order before they are probed.
+	if got, err := p.PickPeer(); err != nil || got != hosts[0] {
+		t.Fatalf("expected %s, got %s, %v", hosts[0], got, err)
+	}
+
+	// 10.0.0.3 is not probed successfully, it is still healthy but
+	// picked after peers with latency.
+	p.Probe(context.Background())
+
+	tests := []struct {
+		exclude []string
+		expect  string
+		err     error
+	}{
+		{expect: hosts[1]},
+		{exclude: hosts[1:2], expect: hosts[0]},
+		{exclude: hosts[:2], expect: hosts[2]},
+		{exclude: hosts, err: ErrNoHealthyPeer},
+	}
+
+	for _, tt := range tests {
+		got, err := p.PickPeer(tt.exclude...)
+		if got != tt.expect || !errors.Is(err, tt.err) {
+			t.Errorf("PickPeer(%q) = %s, %v, expected %s, %v", tt.exclude, got, err, tt.expect, tt.err)
+		}
+	}
+}
+
+func TestStartClose(t *testing.T) {
+	h := newTestHealth()
+	h.setStatus("10.0.0.1", http.StatusOK)
+	host := "10.0.0.1:65004"
+	p := newTestPool(t, h, []string{host}, WithProbeInterval(time.Millisecond))
+
+	p.Start()
+	deadline := time.Now().Add(time.Second)
+	for peerStatus(t, p, host).LastProbe.IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected peer probed after Start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	p.Close()
+	p.Close()
+
+	// Close without Start does not block.
+	newTestPool(t, h, []string{host}).Close()
+}
@@ -0,0 +1,596 @@
+// Package scheduler finds the peers and seed peers holding a task and how many
+// pieces each of them has finished, so that data can be scheduled to a peer
+// which already has it.
+//
+// Schedulers only serve gRPC on config.DefaultSchedulerPort, so peers of a task
+// are queried through the get_task job of Dragonfly manager open API (Dragonfly
+// v2.1 and later). Manager runs the job on schedulers and returns the peers they know:
+//
+//	POST /oapi/v1/jobs      {"type": "get_task", "args": {"task_id": "..."}}
+//	GET  /oapi/v1/jobs/{id} until job state is SUCCESS or FAILURE
+//
+// Requests carry the personal access token set by WithToken.
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"urchinfs/config"
+	"urchinfs/dfstore"
+	"urchinfs/idgen"
+	"urchinfs/urchin"
+)
+
+const (
+	// DefaultPollInterval is the default interval of polling job state.
+	DefaultPollInterval = time.Second
+
+	// maxErrorBodySize is the maximum size of response body kept in error.
+	maxErrorBodySize = 4096
+
+	// jobsPath is the path of jobs of manager open API.
+	jobsPath = "oapi/v1/jobs"
+
+	// jobTypeGetTask is the job type of getting peers of task.
+	jobTypeGetTask = "get_task"
+)
+
+// Job states of manager.
+const (
+	JobStatePending  = "PENDING"
+	JobStateReceived = "RECEIVED"
+	JobStateStarted  = "STARTED"
+	JobStateSuccess  = "SUCCESS"
+	JobStateFailure  = "FAILURE"
+)
+
+// Host types of peers, hosts other than HostTypeNormal are seed peers.
+const (
+	HostTypeNormal = "normal"
+	HostTypeSuper  = "super"
+	HostTypeStrong = "strong"
+	HostTypeWeak   = "weak"
+)
+
+// ErrTaskNotFound means no scheduler has the task.
+var ErrTaskNotFound = errors.New("task not found")
+
+// TaskPeer is a peer holding data of task.
+type TaskPeer struct {
+	// ID is the peer id.
+	ID string
+
+	// Hostname is the hostname of peer.
+	Hostname string
+
+	// IP is the ip of peer.
+	IP string
+
+	// HostType is HostTypeNormal for peers, seed peers are of the other host types.
+	HostType string
+
+	// FinishedPieces is the number of pieces peer has finished.
+	FinishedPieces int
+
+	// TotalPieces is the number of pieces of task, it is zero if unknown.
+	TotalPieces int
+
+	// ContentLength is the content length of task, it is -1 if unknown.
+	ContentLength int64
+
+	// CreatedAt is the time peer joined the task.
+	CreatedAt time.Time
+
+	// UpdatedAt is the time peer was last updated.
+	UpdatedAt time.Time
+
+	// SchedulerClusterID is the id of scheduler cluster of peer.
+	SchedulerClusterID uint
+
+	// Port is the object storage port of peer, it is set by WithObjectStoragePort
+	// if scheduler does not report it.
+	Port int
+}
+
+// DstPeer returns the peer host used as dstPeer of requests, e.g. 127.0.0.1:65004.
+func (p *TaskPeer) DstPeer() string {
+	return net.JoinHostPort(p.IP, strconv.Itoa(p.Port))
+}
+
+// IsSeed returns whether peer is a seed peer.
+func (p *TaskPeer) IsSeed() bool {
+	return p.HostType != "" && p.HostType != HostTypeNormal
+}
+
+// Completion returns the finished fraction of task on peer, from 0 to 1,
+// it is 0 if the number of pieces is unknown.
+func (p *TaskPeer) Completion() float64 {
+	if p.TotalPieces <= 0 {
+		return 0
+	}
+
+	if p.FinishedPieces >= p.TotalPieces {
+		return 1
+	}
+
+	return float64(p.FinishedPieces) / float64(p.TotalPieces)
+}
+
+// IsComplete returns whether peer has all pieces of task.
+func (p *TaskPeer) IsComplete() bool {
+	return p.TotalPieces > 0 && p.FinishedPieces >= p.TotalPieces
+}
+
+// Task is a task and the peers holding it.
+type Task struct {
+	// ID is the task id.
+	ID string
+
+	// URL is the signed url of object peers download, it is set by GetTaskByURLWithContext.
+	URL string
+
+	// Peers is the peers and seed peers holding the task.
+	Peers []TaskPeer
+}
+
+// SeedPeers returns seed peers holding the task.
+func (t *Task) SeedPeers() []TaskPeer {
+	var peers []TaskPeer
+	for _, peer := range t.Peers {
+		if peer.IsSeed() {
+			peers = append(peers, peer)
+		}
+	}
+
+	return peers
+}
+
+// NormalPeers returns peers holding the task which are not seed peers.
+func (t *Task) NormalPeers() []TaskPeer {
+	var peers []TaskPeer
+	for _, peer := range t.Peers {
+		if !peer.IsSeed() {
+			peers = append(peers, peer)
+		}
+	}
+
+	return peers
+}
+
+// CompletePeers returns peers and seed peers which have all pieces of the task.
+func (t *Task) CompletePeers() []TaskPeer {
+	var peers []TaskPeer
+	for _, peer := range t.Peers {
+		if peer.IsComplete() {
+			peers = append(peers, peer)
+		}
+	}
+
+	return peers
+}
+
+// createJobRequest is the request body of creating job.
+type createJobRequest struct {
+	Type                string         `json:"type"`
+	Args                getTaskJobArgs `json:"args"`
+	SchedulerClusterIDs []uint         `json:"scheduler_cluster_ids,omitempty"`
+}
+
+// getTaskJobArgs is the args of get_task job.
+type getTaskJobArgs struct {
+	TaskID string `json:"task_id"`
+}
+
+// job is the job returned by manager.
+type job struct {
+	ID     uint       `json:"id"`
+	State  string     `json:"state"`
+	Result *jobResult `json:"result"`
+}
+
+// jobResult is the result of job, with a job state per scheduler.
+type jobResult struct {
+	State     string     `json:"state"`
+	JobStates []jobState `json:"job_states"`
+}
+
+// jobState is the state of job on a scheduler.
+type jobState struct {
+	State   string           `json:"state"`
+	Error   string           `json:"error"`
+	Results []getTaskResults `json:"results"`
+}
+
+// getTaskResults is the result of get_task job on a scheduler.
+type getTaskResults struct {
+	Peers              []peer `json:"peers"`
+	SchedulerClusterID uint   `json:"scheduler_cluster_id"`
+}
+
+// peer is the peer of scheduler in get_task job results, it is encoded
+// without json tags, so the keys are the field names of scheduler.
+type peer struct {
+	ID             string
+	FinishedPieces pieceSet
+	Task           *peerTask
+	Host           *peerHost
+	CreatedAt      jsonTime
+	UpdatedAt      jsonTime
+}
+
+// peerTask is the task of peer in get_task job results.
+type peerTask struct {
+	ContentLength   *int64
+	TotalPieceCount int32
+}
+
+// peerHost is the host of peer in get_task job results.
+type peerHost struct {
+	Type              int
+	Hostname          string
+	IP                string
+	ObjectStoragePort int32
+}
+
+// hostTypes is the names of host types reported by scheduler.
+var hostTypes = []string{HostTypeNormal, HostTypeSuper, HostTypeStrong, HostTypeWeak}
+
+// taskPeer returns TaskPeer of peer, port is used if scheduler does not report it.
+func (p *peer) taskPeer(schedulerClusterID uint, port int) TaskPeer {
+	taskPeer := TaskPeer{
+		ID:                 p.ID,
+		FinishedPieces:     p.FinishedPieces.count,
+		ContentLength:      -1,
+		CreatedAt:          time.Time(p.CreatedAt),
+		UpdatedAt:          time.Time(p.UpdatedAt),
+		SchedulerClusterID: schedulerClusterID,
+		Port:               port,
+	}
+
+	if p.Task != nil {
+		taskPeer.TotalPieces = int(p.Task.TotalPieceCount)
+		if p.Task.ContentLength != nil {
+			taskPeer.ContentLength = *p.Task.ContentLength
+		}
+	}
+
+	if p.Host != nil {
+		taskPeer.Hostname = p.Host.Hostname
+		taskPeer.IP = p.Host.IP
+		if p.Host.Type >= 0 && p.Host.Type < len(hostTypes) {
+			taskPeer.HostType = hostTypes[p.Host.Type]
+		}
+
+		if p.Host.ObjectStoragePort > 0 {
+			taskPeer.Port = int(p.Host.ObjectStoragePort)
+		}
+	}
+
+	return taskPeer
+}
+
+// pieceSet is the finished pieces of peer, a bitset encoded as url base64 of
+// the number of bits and then the words, all of them are uint64 in big endian.
+type pieceSet struct {
+	count int
+}
+
+// UnmarshalJSON counts finished pieces of bitset.
+func (s *pieceSet) UnmarshalJSON(data []byte) error {
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+
+	if encoded == "" {
+		s.count = 0
+		return nil
+	}
+
+	raw, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("invalid finished pieces: %w", err)
+	}
+
+	if len(raw) < 8 || len(raw)%8 != 0 {
+		return fmt.Errorf("invalid finished pieces of %d bytes", len(raw))
+	}
+
+	length := binary.BigEndian.Uint64(raw)
+	s.count = 0
+	for i, offset := uint64(0), 8; offset < len(raw) && i*64 < length; i, offset = i+1, offset+8 {
+		word := binary.BigEndian.Uint64(raw[offset:])
+		if n := length - i*64; n < 64 {
+			word &= 1<<n - 1
+		}
+		s.count += bits.OnesCount64(word)
+	}
+
+	return nil
+}
+
+// jsonTime is time of peer, values other than RFC 3339 strings are ignored.
+type jsonTime time.Time
+
+// UnmarshalJSON parses RFC 3339 time.
+func (t *jsonTime) UnmarshalJSON(data []byte) error {
+	var v time.Time
+	if err := json.Unmarshal(data, &v); err == nil {
+		*t = jsonTime(v)
+	}
+
+	return nil
+}
+
+// Client is the client querying peers of tasks from schedulers through manager.
+type Client struct {
+	endpoint            string
+	token               string
+	httpClient          *http.Client
+	pollInterval        time.Duration
+	objectStoragePort   int
+	schedulerClusterIDs []uint
+	urchinfs            urchin.Urchinfs
+}
+
+// Option is a functional option for configuring the client.
+type Option func(c *Client)
+
+// WithEndpoint set address of manager, default is built from
+// config.DefaultManagerSchema, DefaultManagerIP and DefaultManagerPort.
+func WithEndpoint(endpoint string) Option {
+	return func(c *Client) {
+		c.endpoint = endpoint
+	}
+}
+
+// WithToken set personal access token of manager open API.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithHTTPClient set http client used to request manager.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = client
+	}
+}
+
+// WithPollInterval set interval of polling job state, default is DefaultPollInterval.
+func WithPollInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.pollInterval = interval
+	}
+}
+
+// WithObjectStoragePort set object storage port of peers used by TaskPeer.DstPeer
+// if scheduler does not report it, default is config.DefaultObjectStorageStartPort.
+func WithObjectStoragePort(port int) Option {
+	return func(c *Client) {
+		c.objectStoragePort = port
+	}
+}
+
+// WithSchedulerClusterIDs set scheduler clusters asked for peers, default is all clusters.
+func WithSchedulerClusterIDs(ids ...uint) Option {
+	return func(c *Client) {
+		c.schedulerClusterIDs = ids
+	}
+}
+
+// WithUrchinfs set urchinfs used to resolve urfs urls, default is urchin.New().
+func WithUrchinfs(urchinfs urchin.Urchinfs) Option {
+	return func(c *Client) {
+		c.urchinfs = urchinfs
+	}
+}
+
+// New scheduler client.
+func New(options ...Option) (*Client, error) {
+	c := &Client{
+		endpoint: (&url.URL{
+			Scheme: config.DefaultManagerSchema,
+			Host:   net.JoinHostPort(config.DefaultManagerIP, strconv.Itoa(config.DefaultManagerPort)),
+		}).String(),
+		httpClient:        http.DefaultClient,
+		pollInterval:      DefaultPollInterval,
+		objectStoragePort: config.DefaultObjectStorageStartPort,
+	}
+
+	for _, opt := range options {
+		opt(c)
+	}
+
+	if c.httpClient == nil {
+		return nil, errors.New("scheduler client requires http client")
+	}
+
+	if c.pollInterval <= 0 {
+		return nil, fmt.Errorf("invalid poll interval %s", c.pollInterval)
+	}
+
+	if _, err := url.ParseRequestURI(c.endpoint); err != nil {
+		return nil, fmt.Errorf("invalid manager endpoint: %w", err)
+	}
+
+	if c.urchinfs == nil {
+		urchinfs, err := urchin.New()
+		if err != nil {
+			return nil, err
+		}
+		c.urchinfs = urchinfs
+	}
+
+	return c, nil
+}
+
+// GetTaskWithContext returns task of task id and the peers holding it, e.g.
+// PeerResult.TaskID, error matches ErrTaskNotFound if no scheduler has the task.
+func (c *Client) GetTaskWithContext(ctx context.Context, taskID string) (*Task, error) {
+	if taskID == "" {
+		return nil, errors.New("invalid TaskID")
+	}
+
+	j := &job{}
+	if err := c.doJSON(ctx, http.MethodPost, jobsPath, &createJobRequest{
+		Type:                jobTypeGetTask,
+		Args:                getTaskJobArgs{TaskID: taskID},
+		SchedulerClusterIDs: c.schedulerClusterIDs,
+	}, j); err != nil {
+		return nil, err
+	}
+
+	for j.State != JobStateSuccess {
+		if j.State == JobStateFailure {
+			return nil, fmt.Errorf("get_task job %d of task %s failed: %s", j.ID, taskID, j.errorMessage())
+		}
+
+		timer := time.NewTimer(c.pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		id := j.ID
+		j = &job{}
+		if err := c.doJSON(ctx, http.MethodGet, path.Join(jobsPath, strconv.FormatUint(uint64(id), 10)), nil, j); err != nil {
+			return nil, err
+		}
+	}
+
+	task := &Task{ID: taskID}
+	if j.Result != nil {
+		for _, state := range j.Result.JobStates {
+			for _, result := range state.Results {
+				for _, peer := range result.Peers {
+					task.Peers = append(task.Peers, peer.taskPeer(result.SchedulerClusterID, c.objectStoragePort))
+				}
+			}
+		}
+	}
+
+	// Scheduler returns no peers if it has no such task.
+	if len(task.Peers) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+
+	return task, nil
+}
+
+// GetTaskByURLWithContext returns task of object of urfs url, e.g. urfs://endpoint/bucket/key.
+// The object is resolved by stat on dstPeer to the signed url peers download, the task id
+// is the one reported by dstPeer, or computed by idgen.TaskIDFromMeta with the signed url
+// and meta if dstPeer reports none, meta is optional.
+func (c *Client) GetTaskByURLWithContext(ctx context.Context, urfsURL, dstPeer string, meta *idgen.URLMeta) (*Task, error) {
+	endpoint, bucketName, objectKey, err := urchin.ParseUrfsURL(urfsURL)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.urchinfs.StatObjectWithContext(ctx, endpoint, bucketName, objectKey, dstPeer)
+	if err != nil {
+		if errors.Is(err, dfstore.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, urfsURL)
+		}
+
+		return nil, err
+	}
+
+	taskID := result.TaskID
+	if taskID == "" {
+		if result.SignedUrl == "" {
+			return nil, fmt.Errorf("peer %s reports neither task id nor signed url of %s", dstPeer, urfsURL)
+		}
+		taskID = idgen.TaskIDFromMeta(result.SignedUrl, meta)
+	}
+
+	task, err := c.GetTaskWithContext(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	task.URL = result.SignedUrl
+
+	return task, nil
+}
+
+// errorMessage returns errors of job on schedulers.
+func (j *job) errorMessage() string {
+	if j.Result == nil {
+		return "unknown error"
+	}
+
+	var msgs []string
+	for _, state := range j.Result.JobStates {
+		if state.Error != "" {
+			msgs = append(msgs, state.Error)
+		}
+	}
+
+	if len(msgs) == 0 {
+		return "unknown error"
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// doJSON sends request with json body to manager and decodes json response to out.
+func (c *Client) doJSON(ctx context.Context, method, p string, in, out interface{}) error {
+	u, err := url.Parse(c.endpoint)
+	if err != nil {
+		return err
+	}
+	u.Path = path.Join(u.Path, p)
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return err
+	}
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return fmt.Errorf("%s %s: bad response status %s: %s", req.Method, req.URL, resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,211 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"urchinfs/idgen"
+)
+
+const testTaskID = "317e68d57c97b7f1c95de00fa67bdcfa04de9d9382aa8dd1b0fad1c6bc98e736"
+
+// testSignedURL is the signed url of object urfs://endpoint/bucket/key, its task id
+// is testTaskID with testFilter.
+const testSignedURL = "https://bucket.obs.example.com/data/model.bin?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=AKIAEXAMPLE%2F20230301%2Fus-east-1%2Fs3%2Faws4_request&X-Amz-Date=20230301T080000Z&X-Amz-Expires=3600&X-Amz-SignedHeaders=host&X-Amz-Signature=6e2b6b4a1c"
+
+const testFilter = "X-Amz-Algorithm&X-Amz-Credential&X-Amz-Date&X-Amz-Expires&X-Amz-SignedHeaders&X-Amz-Signature"
+
+// readJob reads job response of manager in testdata. get_task_job.json is in the
+// encoding of Dragonfly v2.1 manager, i.e. models.Job with results of scheduler
+// peers encoded without json tags, finished pieces are url base64 of bitset.
+func readJob(t *testing.T, name string) string {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+// newManager returns manager serving get_task job of testTaskID, the job is
+// started on first poll and responded with job afterwards.
+func newManager(t *testing.T, job string) *httptest.Server {
+	var polls int32
+	manager := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("unexpected Authorization %q", got)
+		}
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/oapi/v1/jobs":
+			if got := r.Header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("unexpected Content-Type %q", got)
+			}
+
+			body, _ := io.ReadAll(r.Body)
+			var req map[string]interface{}
+			if err := json.Unmarshal(body, &req); err != nil {
+				t.Fatal(err)
+			}
+
+			if req["type"] != "get_task" {
+				t.Errorf("unexpected job type %v", req["type"])
+			}
+
+			if args, _ := req["args"].(map[string]interface{}); args["task_id"] != testTaskID {
+				t.Errorf("unexpected job args %s", body)
+			}
+
+			io.WriteString(w, `{"id": 7, "type": "get_task", "state": "PENDING"}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/oapi/v1/jobs/7":
+			if atomic.AddInt32(&polls, 1) == 1 {
+				io.WriteString(w, `{"id": 7, "type": "get_task", "state": "STARTED"}`)
+				return
+			}
+
+			io.WriteString(w, job)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(manager.Close)
+
+	return manager
+}
+
+// newPeer returns peer responding stat of urfs://endpoint/bucket/key with status and body.
+func newPeer(t *testing.T, status int, body string) string {
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/buckets/bucket.endpoint/stat_object/key" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(peer.Close)
+
+	return peer.Listener.Addr().String()
+}
+
+func newTestClient(t *testing.T, manager *httptest.Server) *Client {
+	c, err := New(WithEndpoint(manager.URL), WithToken("token"), WithPollInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestGetTask(t *testing.T) {
+	manager := newManager(t, readJob(t, "get_task_job.json"))
+	peer := newPeer(t, http.StatusOK, `{"Content-Length": "16777216", "SignedUrl": "`+strings.ReplaceAll(testSignedURL, "&", `\u0026`)+`", "StatusCode": 0}`)
+
+	task, err := newTestClient(t, manager).GetTaskByURLWithContext(context.Background(), "urfs://endpoint/bucket/key", peer, &idgen.URLMeta{
+		Filter: testFilter,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if task.ID != testTaskID || task.URL != testSignedURL || len(task.Peers) != 3 {
+		t.Fatalf("unexpected task %+v", task)
+	}
+
+	seeds := task.SeedPeers()
+	if len(seeds) != 1 || seeds[0].Hostname != "seed-peer-0" || seeds[0].HostType != HostTypeSuper || seeds[0].SchedulerClusterID != 1 {
+		t.Errorf("unexpected seed peers %+v", seeds)
+	}
+
+	peers := task.NormalPeers()
+	if len(peers) != 2 || peers[1].IP != "10.0.1.1" || peers[1].SchedulerClusterID != 2 {
+		t.Fatalf("unexpected peers %+v", peers)
+	}
+
+	partial := peers[0]
+	if partial.FinishedPieces != 2 || partial.TotalPieces != 4 || partial.Completion() != 0.5 || partial.IsComplete() {
+		t.Errorf("unexpected completion of %+v", partial)
+	}
+
+	if partial.ContentLength != 16777216 || partial.CreatedAt.IsZero() || partial.DstPeer() != "10.0.0.1:65004" {
+		t.Errorf("unexpected peer %+v", partial)
+	}
+
+	complete := task.CompletePeers()
+	if len(complete) != 2 || complete[0].Hostname != "seed-peer-0" || complete[1].Hostname != "node-2" || complete[1].Completion() != 1 {
+		t.Errorf("unexpected complete peers %+v", complete)
+	}
+}
+
+func TestGetTaskByURLNotFound(t *testing.T) {
+	peer := newPeer(t, http.StatusNotFound, "object not found")
+
+	c, err := New(WithEndpoint("http://127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.GetTaskByURLWithContext(context.Background(), "urfs://endpoint/bucket/key", peer, nil); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+
+	if _, err := c.GetTaskByURLWithContext(context.Background(), "https://example.com/key", peer, nil); err == nil {
+		t.Fatal("expected error of url other than urfs")
+	}
+}
+
+func TestPieceSet(t *testing.T) {
+	tests := []struct {
+		data   string
+		expect int
+	}{
+		{data: `null`},
+		{data: `""`},
+		// 70 bits with bits 0, 63, 64 and 69 set.
+		{data: `"AAAAAAAAAEaAAAAAAAAAAQAAAAAAAAAh"`, expect: 4},
+		// Bits beyond the length are not counted.
+		{data: `"AAAAAAAAAAIAAAAAAAAADw=="`, expect: 2},
+	}
+
+	for _, tt := range tests {
+		var s pieceSet
+		if err := json.Unmarshal([]byte(tt.data), &s); err != nil || s.count != tt.expect {
+			t.Errorf("unmarshal %s = %d, %v, expected %d", tt.data, s.count, err, tt.expect)
+		}
+	}
+
+	for _, data := range []string{`"!"`, `"AAAA"`, `1`} {
+		var s pieceSet
+		if err := json.Unmarshal([]byte(data), &s); err == nil {
+			t.Errorf("expected error of %s", data)
+		}
+	}
+}
+
+func TestGetTaskNotFound(t *testing.T) {
+	manager := newManager(t, `{"id": 7, "type": "get_task", "state": "SUCCESS", "result": {"state": "SUCCESS", "job_states": [{"state": "SUCCESS", "results": [{"scheduler_cluster_id": 1, "peers": null}]}]}}`)
+
+	_, err := newTestClient(t, manager).GetTaskWithContext(context.Background(), testTaskID)
+	if !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestGetTaskJobFailure(t *testing.T) {
+	manager := newManager(t, `{"id": 7, "type": "get_task", "state": "FAILURE", "result": {"state": "FAILURE", "job_states": [{"state": "FAILURE", "error": "scheduler unavailable"}]}}`)
+
+	_, err := newTestClient(t, manager).GetTaskWithContext(context.Background(), testTaskID)
+	if err == nil || !strings.Contains(err.Error(), "scheduler unavailable") {
+		t.Fatalf("expected job failure, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package urchin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel/trace"
+	"net"
+	urfs "urchinfs/dfstore"
+	"urchinfs/peerpool"
+)
+
+// WithPeerPool set peer pool used by Schedule...ToAnyPeer methods.
+func WithPeerPool(pool *peerpool.Pool) Option {
+	return func(urfs *urchinfs) {
+		urfs.pool = pool
+	}
+}
+
+func (urfs *urchinfs) ScheduleDataToAnyPeerByKeyWithContext(ctx context.Context, endpoint, bucketName, objectKey string, overwrite bool) (string, *PeerResult, error) {
+	return urfs.traceAnyPeer(ctx, "ScheduleDataToAnyPeerByKey", endpoint, bucketName, objectKey, func(ctx context.Context, peer string) (*PeerResult, error) {
+		return urfs.ScheduleDataToPeerByKeyWithContext(ctx, endpoint, bucketName, objectKey, peer, overwrite)
+	})
+}
+
+func (urfs *urchinfs) ScheduleDirToAnyPeerByKeyWithContext(ctx context.Context, endpoint, bucketName, objectKey string) (string, *PeerResult, error) {
+	return urfs.traceAnyPeer(ctx, "ScheduleDirToAnyPeerByKey", endpoint, bucketName, objectKey, func(ctx context.Context, peer string) (*PeerResult, error) {
+		return urfs.ScheduleDirToPeerByKeyWithContext(ctx, endpoint, bucketName, objectKey, peer)
+	})
+}
+
+// traceAnyPeer calls withAnyPeer in span of operation on object, every attempt
+// is a child span and the peer used is recorded.
+func (urfs *urchinfs) traceAnyPeer(ctx context.Context, operation, endpoint, bucketName, objectKey string, fn func(ctx context.Context, peer string) (*PeerResult, error)) (string, *PeerResult, error) {
+	var peer string
+	result, err := urfs.traceResult(ctx, operation, endpoint, bucketName, objectKey, "", func(ctx context.Context) (*PeerResult, error) {
+		var (
+			result *PeerResult
+			err    error
+		)
+		peer, result, err = urfs.withAnyPeer(ctx, func(peer string) (*PeerResult, error) {
+			return fn(ctx, peer)
+		})
+		if peer != "" {
+			trace.SpanFromContext(ctx).SetAttributes(attributePeer.String(peer))
+		}
+
+		return result, err
+	})
+
+	return peer, result, err
+}
+
+// withAnyPeer calls fn with the best healthy peer of pool, and fails over
+// to the next one if peer is unreachable or unavailable.
+func (urfs *urchinfs) withAnyPeer(ctx context.Context, fn func(peer string) (*PeerResult, error)) (string, *PeerResult, error) {
+	if urfs.pool == nil {
+		return "", nil, errors.New("urchinfs requires peer pool")
+	}
+
+	var (
+		tried   []string
+		lastErr error
+	)
+	for {
+		peer, err := urfs.pool.PickPeer(tried...)
+		if err != nil {
+			if lastErr != nil {
+				return "", nil, fmt.Errorf("%d peers failed, last error: %w", len(tried), lastErr)
+			}
+
+			return "", nil, err
+		}
+
+		result, err := fn(peer)
+		if err == nil {
+			return peer, result, nil
+		}
+
+		if ctx.Err() != nil || !peerFailed(err) {
+			return peer, nil, err
+		}
+
+		urfs.pool.ReportFailure(peer, err)
+		urfs.logf("peer %s failed, try next peer: %v", peer, err)
+		tried = append(tried, peer)
+		lastErr = err
+	}
+}
+
+// peerFailed returns whether err is caused by peer rather than the request,
+// so that the request may succeed on other peers.
+func peerFailed(err error) bool {
+	if errors.Is(err, urfs.ErrPeerUnavailable) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,113 @@
+package urchin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	urfs "urchinfs/dfstore"
+	"urchinfs/peerpool"
+)
+
+// newTestPeerServer returns peer which responds status to every request,
+// and schedules object successfully if status is 200.
+func newTestPeerServer(t *testing.T, status int) (string, *int) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", "5")
+			return
+		}
+
+		if r.Method == http.MethodPost && strings.Contains(r.URL.Path, "/cache_object/") {
+			w.Write([]byte(`{"Content-Length": "5", "StatusCode": 0}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	t.Cleanup(server.Close)
+
+	return server.Listener.Addr().String(), &requests
+}
+
+func newTestAnyPeer(t *testing.T, hosts ...string) (Urchinfs, *peerpool.Pool) {
+	pool, err := peerpool.New(hosts, peerpool.WithFailureThreshold(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ufs, err := New(WithPeerPool(pool))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ufs, pool
+}
+
+func TestScheduleToAnyPeerFailover(t *testing.T) {
+	unavailable, _ := newTestPeerServer(t, http.StatusServiceUnavailable)
+	available, _ := newTestPeerServer(t, http.StatusOK)
+	ufs, pool := newTestAnyPeer(t, unavailable, available)
+
+	peer, result, err := ufs.ScheduleDataToAnyPeerByKeyWithContext(context.Background(), "endpoint", "bucket", "key", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if peer != available || result.Size != 5 {
+		t.Fatalf("expected result of %s, got %s, %+v", available, peer, result)
+	}
+
+	// The unavailable peer is reported and not picked again.
+	for _, status := range pool.Peers() {
+		if status.Healthy != (status.Host == available) {
+			t.Errorf("unexpected peer status %+v", status)
+		}
+	}
+
+	if got, err := pool.PickPeer(); err != nil || got != available {
+		t.Errorf("expected %s picked, got %s, %v", available, got, err)
+	}
+}
+
+func TestScheduleToAnyPeerAllFailed(t *testing.T) {
+	first, _ := newTestPeerServer(t, http.StatusBadGateway)
+	second, _ := newTestPeerServer(t, http.StatusServiceUnavailable)
+	ufs, _ := newTestAnyPeer(t, first, second)
+
+	_, _, err := ufs.ScheduleDataToAnyPeerByKeyWithContext(context.Background(), "endpoint", "bucket", "key", false)
+	if !errors.Is(err, urfs.ErrPeerUnavailable) || !strings.Contains(err.Error(), "2 peers failed") {
+		t.Fatalf("expected all peers unavailable, got %v", err)
+	}
+}
+
+func TestScheduleToAnyPeerNoFailover(t *testing.T) {
+	notFound, _ := newTestPeerServer(t, http.StatusNotFound)
+	available, requests := newTestPeerServer(t, http.StatusOK)
+	ufs, pool := newTestAnyPeer(t, notFound, available)
+
+	// Errors of request rather than peer are returned without failover.
+	peer, _, err := ufs.ScheduleDataToAnyPeerByKeyWithContext(context.Background(), "endpoint", "bucket", "key", false)
+	if peer != notFound || !errors.Is(err, urfs.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound of %s, got %s, %v", notFound, peer, err)
+	}
+
+	if *requests != 0 {
+		t.Errorf("expected no request to %s, got %d", available, *requests)
+	}
+
+	for _, status := range pool.Peers() {
+		if !status.Healthy || status.Failures != 0 {
+			t.Errorf("unexpected peer status %+v", status)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package urchin
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	urfs "urchinfs/dfstore"
+)
+
+func (urfs *urchinfs) StatObjectWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string) (*PeerResult, error) {
+	return urfs.traceResult(ctx, "StatObject", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) (*PeerResult, error) {
+		ctx, cancel := urfs.withTimeout(ctx)
+		defer cancel()
+
+		return processStatObject(ctx, urfs.dfs, endpoint, bucketName, objectKey, destPeerHost)
+	})
+}
+
+func (urfs *urchinfs) ImportObjectWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost, filePath string) (*PeerResult, error) {
+	return urfs.traceResult(ctx, "ImportObject", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) (*PeerResult, error) {
+		return processImportObject(ctx, urfs.dfs, endpoint, bucketName, objectKey, destPeerHost, filePath)
+	})
+}
+
+func (urfs *urchinfs) ExportObjectWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost, outputPath string) error {
+	return urfs.trace(ctx, "ExportObject", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) error {
+		return processExportObject(ctx, urfs.dfs, endpoint, bucketName, objectKey, destPeerHost, outputPath)
+	})
+}
+
+func (urfs *urchinfs) ExportObjectToBucketWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost, targetEndpoint, targetBucketName, targetObjectKey string) (*PeerResult, error) {
+	return urfs.traceResult(ctx, "ExportObjectToBucket", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) (*PeerResult, error) {
+		ctx, cancel := urfs.withTimeout(ctx)
+		defer cancel()
+
+		return processExportObjectToBucket(ctx, urfs.dfs, endpoint, bucketName, objectKey, destPeerHost, targetEndpoint, targetBucketName, targetObjectKey)
+	})
+}
+
+func (urfs *urchinfs) DeleteObjectWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string) error {
+	return urfs.trace(ctx, "DeleteObject", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) error {
+		ctx, cancel := urfs.withTimeout(ctx)
+		defer cancel()
+
+		return processDeleteObject(ctx, urfs.dfs, endpoint, bucketName, objectKey, destPeerHost)
+	})
+}
+
+// Stat object in peer cache.
+func processStatObject(ctx context.Context, dfs urfs.Dfstore, endpoint, bucketName, objectKey, dstPeer string) (*PeerResult, error) {
+	reader, err := dfs.StatUrfsWithContext(ctx, &urfs.GetUrfsInput{
+		Endpoint:   endpoint,
+		BucketName: bucketName,
+		ObjectKey:  objectKey,
+		DstPeer:    dstPeer,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return decodePeerResult(reader)
+}
+
+// Import local file to peer cache.
+func processImportObject(ctx context.Context, dfs urfs.Dfstore, endpoint, bucketName, objectKey, dstPeer, filePath string) (*PeerResult, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := dfs.ImportUrfsWithContext(ctx, &urfs.ImportUrfsInput{
+		Endpoint:      endpoint,
+		BucketName:    bucketName,
+		ObjectKey:     objectKey,
+		DstPeer:       dstPeer,
+		Reader:        f,
+		ContentLength: info.Size(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return decodePeerResult(reader)
+}
+
+// exportFileMode is the mode of exported file if it does not exist.
+const exportFileMode os.FileMode = 0644
+
+// Export object in peer cache to local file, the file is replaced only if export succeeds.
+func processExportObject(ctx context.Context, dfs urfs.Dfstore, endpoint, bucketName, objectKey, dstPeer, outputPath string) error {
+	reader, err := dfs.ExportUrfsWithContext(ctx, &urfs.ExportUrfsInput{
+		Endpoint:   endpoint,
+		BucketName: bucketName,
+		ObjectKey:  objectKey,
+		DstPeer:    dstPeer,
+	})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	f, err := os.CreateTemp(filepath.Dir(outputPath), "."+filepath.Base(outputPath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, reader); err != nil {
+		f.Close()
+		return err
+	}
+
+	// Temp file is owner-only, keep mode of the replaced file.
+	mode := exportFileMode
+	if info, err := os.Stat(outputPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return err
+	}
+
+	// Data must be on disk before rename, or a crash may leave a truncated file.
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), outputPath)
+}
+
+// Export object in peer cache to bucket.
+func processExportObjectToBucket(ctx context.Context, dfs urfs.Dfstore, endpoint, bucketName, objectKey, dstPeer, targetEndpoint, targetBucketName, targetObjectKey string) (*PeerResult, error) {
+	reader, err := dfs.ExportUrfsWithContext(ctx, &urfs.ExportUrfsInput{
+		Endpoint:         endpoint,
+		BucketName:       bucketName,
+		ObjectKey:        objectKey,
+		DstPeer:          dstPeer,
+		TargetEndpoint:   targetEndpoint,
+		TargetBucketName: targetBucketName,
+		TargetObjectKey:  targetObjectKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return decodePeerResult(reader)
+}
+
+// Delete object from peer cache.
+func processDeleteObject(ctx context.Context, dfs urfs.Dfstore, endpoint, bucketName, objectKey, dstPeer string) error {
+	return dfs.DeleteUrfsWithContext(ctx, &urfs.GetUrfsInput{
+		Endpoint:   endpoint,
+		BucketName: bucketName,
+		ObjectKey:  objectKey,
+		DstPeer:    dstPeer,
+	})
+}
@@ -0,0 +1,114 @@
+package urchin
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+)
+
+// DirScheduleMode decides how files already cached in peer are handled
+// when scheduling folder.
+type DirScheduleMode int
+
+const (
+	// DirScheduleSkipExisting fetches files not cached in peer, cached files are kept.
+	DirScheduleSkipExisting DirScheduleMode = iota
+
+	// DirScheduleOverwrite fetches all files of folder again.
+	DirScheduleOverwrite
+
+	// DirScheduleIncremental fetches files not cached in peer and files whose
+	// ETag or Last-Modified changed since they were cached.
+	DirScheduleIncremental
+)
+
+// String returns name of the mode.
+func (m DirScheduleMode) String() string {
+	switch m {
+	case DirScheduleSkipExisting:
+		return "skip-existing"
+	case DirScheduleOverwrite:
+		return "overwrite"
+	case DirScheduleIncremental:
+		return "incremental"
+	default:
+		return fmt.Sprintf("DirScheduleMode(%d)", int(m))
+	}
+}
+
+// Validate validates the mode.
+func (m DirScheduleMode) Validate() error {
+	switch m {
+	case DirScheduleSkipExisting, DirScheduleOverwrite, DirScheduleIncremental:
+		return nil
+	default:
+		return fmt.Errorf("invalid dir schedule mode %d", int(m))
+	}
+}
+
+// ParseDirScheduleMode parses mode of name returned by DirScheduleMode.String.
+func ParseDirScheduleMode(s string) (DirScheduleMode, error) {
+	for _, m := range []DirScheduleMode{DirScheduleSkipExisting, DirScheduleOverwrite, DirScheduleIncremental} {
+		if strings.EqualFold(s, m.String()) {
+			return m, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid dir schedule mode %q, must be skip-existing, overwrite or incremental", s)
+}
+
+func (urfs *urchinfs) ScheduleDirToPeerByKeyWithModeContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string, mode DirScheduleMode) (*PeerResult, error) {
+	if err := mode.Validate(); err != nil {
+		return nil, err
+	}
+
+	return urfs.traceResult(ctx, "ScheduleDirToPeerByKeyWithMode", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) (*PeerResult, error) {
+		trace.SpanFromContext(ctx).SetAttributes(attributeDirScheduleMode.String(mode.String()))
+		return urfs.scheduleDirToPeerByKeyWithMode(ctx, endpoint, bucketName, objectKey, destPeerHost, mode)
+	})
+}
+
+// scheduleDirToPeerByKeyWithMode schedules dir to peer in mode, stale files
+// are refreshed before the folder in DirScheduleIncremental mode.
+func (urfs *urchinfs) scheduleDirToPeerByKeyWithMode(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string, mode DirScheduleMode) (*PeerResult, error) {
+	if mode == DirScheduleIncremental {
+		if err := urfs.refreshStaleFiles(ctx, endpoint, bucketName, objectKey, destPeerHost); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := urfs.withTimeout(ctx)
+	defer cancel()
+
+	peerResult, err := processScheduleDirToPeer(ctx, urfs.dfs, endpoint, bucketName, objectKey, destPeerHost, mode == DirScheduleOverwrite)
+	if err != nil {
+		return nil, err
+	}
+	urfs.recordScheduled(destPeerHost, peerResult)
+
+	return peerResult, nil
+}
+
+// refreshStaleFiles schedules files of folder whose cached copy is stale with
+// overwrite, files not cached are left to the folder task.
+func (urfs *urchinfs) refreshStaleFiles(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string) error {
+	var refreshed int
+	if err := urfs.WalkDirWithContext(ctx, endpoint, bucketName, objectKey, destPeerHost, func(entry DirEntry) error {
+		if !entry.Stale() {
+			return nil
+		}
+
+		if _, err := urfs.ScheduleDataToPeerByKeyWithContext(ctx, endpoint, bucketName, entry.Key, destPeerHost, true); err != nil {
+			return fmt.Errorf("refresh %s: %w", entry.Key, err)
+		}
+		refreshed++
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	urfs.logf("refreshed %d stale files of %s/%s/%s in peer %s", refreshed, endpoint, bucketName, objectKey, destPeerHost)
+	return nil
+}
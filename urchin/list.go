@@ -0,0 +1,205 @@
+package urchin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	urfs "urchinfs/dfstore"
+)
+
+// DefaultListPageSize is the number of files requested per page when listing folder.
+const DefaultListPageSize = 1000
+
+// ErrStopWalk can be returned by WalkDirFunc to stop walking without error.
+var ErrStopWalk = errors.New("stop walk")
+
+// DirEntry is a file in folder and its cache status in peer.
+type DirEntry struct {
+	// Key is the object key of file.
+	Key string `json:"Key"`
+
+	// Size is the size of file in object storage.
+	Size int64 `json:"Size"`
+
+	// ETag is the etag of file in object storage.
+	ETag string `json:"ETag"`
+
+	// LastModified is the last modified time of file in object storage.
+	LastModified string `json:"LastModified"`
+
+	// Digest is the digest of file, in format of algorithm:hex.
+	Digest string `json:"Digest"`
+
+	// Cached is whether file is cached in peer.
+	Cached bool `json:"Cached"`
+
+	// CachedETag is the etag of the cached copy.
+	CachedETag string `json:"CachedETag"`
+
+	// CachedLastModified is the last modified time of the cached copy.
+	CachedLastModified string `json:"CachedLastModified"`
+}
+
+// Stale returns whether the cached copy differs from file in object storage.
+func (e *DirEntry) Stale() bool {
+	if !e.Cached {
+		return false
+	}
+
+	if e.ETag != "" && e.CachedETag != "" {
+		return e.ETag != e.CachedETag
+	}
+
+	return e.LastModified != "" && e.CachedLastModified != "" && e.LastModified != e.CachedLastModified
+}
+
+// DirManifest is the files of folder and their cache status in peer.
+type DirManifest struct {
+	Endpoint   string
+	BucketName string
+	ObjectKey  string
+	DstPeer    string
+	Entries    []DirEntry
+}
+
+// TotalSize returns the size of all files.
+func (m *DirManifest) TotalSize() int64 {
+	var size int64
+	for _, e := range m.Entries {
+		size += e.Size
+	}
+
+	return size
+}
+
+// CachedSize returns the size of files cached in peer and not stale.
+func (m *DirManifest) CachedSize() int64 {
+	var size int64
+	for _, e := range m.Entries {
+		if e.Cached && !e.Stale() {
+			size += e.Size
+		}
+	}
+
+	return size
+}
+
+// Pending returns files not cached in peer or stale.
+func (m *DirManifest) Pending() []DirEntry {
+	var entries []DirEntry
+	for _, e := range m.Entries {
+		if !e.Cached || e.Stale() {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries
+}
+
+// Done returns whether all files are cached in peer and not stale.
+func (m *DirManifest) Done() bool {
+	return len(m.Pending()) == 0
+}
+
+// WalkDirFunc is called for every file in folder, walking stops when it returns error.
+type WalkDirFunc func(entry DirEntry) error
+
+// dirPage is a page of folder listing returned by peer.
+type dirPage struct {
+	Entries     []DirEntry `json:"Entries"`
+	NextMarker  string     `json:"NextMarker"`
+	IsTruncated bool       `json:"IsTruncated"`
+}
+
+func (urfs *urchinfs) ListDirWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string) (*DirManifest, error) {
+	manifest := &DirManifest{
+		Endpoint:   endpoint,
+		BucketName: bucketName,
+		ObjectKey:  objectKey,
+		DstPeer:    destPeerHost,
+	}
+
+	if err := urfs.trace(ctx, "ListDir", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) error {
+		return urfs.walkDir(ctx, endpoint, bucketName, objectKey, destPeerHost, func(entry DirEntry) error {
+			manifest.Entries = append(manifest.Entries, entry)
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+func (urfs *urchinfs) WalkDirWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string, fn WalkDirFunc) error {
+	return urfs.trace(ctx, "WalkDir", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) error {
+		return urfs.walkDir(ctx, endpoint, bucketName, objectKey, destPeerHost, fn)
+	})
+}
+
+// walkDir calls fn for every file of folder page by page.
+func (urfs *urchinfs) walkDir(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string, fn WalkDirFunc) error {
+	var marker string
+	for {
+		page, err := urfs.listDirPage(ctx, endpoint, bucketName, objectKey, destPeerHost, marker)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range page.Entries {
+			if err := fn(entry); err != nil {
+				if errors.Is(err, ErrStopWalk) {
+					return nil
+				}
+
+				return err
+			}
+		}
+
+		if !page.IsTruncated {
+			return nil
+		}
+
+		if page.NextMarker == "" || page.NextMarker == marker {
+			return errors.New("invalid NextMarker of truncated listing")
+		}
+		marker = page.NextMarker
+	}
+}
+
+// listDirPage lists a page of folder, request timeout applies to every page.
+func (urfs *urchinfs) listDirPage(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost, marker string) (*dirPage, error) {
+	ctx, cancel := urfs.withTimeout(ctx)
+	defer cancel()
+
+	return processListDir(ctx, urfs.dfs, endpoint, bucketName, objectKey, destPeerHost, marker, DefaultListPageSize)
+}
+
+// List a page of files in folder and their cache status in peer.
+func processListDir(ctx context.Context, dfs urfs.Dfstore, endpoint, bucketName, objectKey, dstPeer, marker string, limit int) (*dirPage, error) {
+	reader, err := dfs.ListUrfsWithContext(ctx, &urfs.ListUrfsInput{
+		Endpoint:   endpoint,
+		BucketName: bucketName,
+		ObjectKey:  objectKey,
+		DstPeer:    dstPeer,
+		Marker:     marker,
+		Limit:      limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &dirPage{}
+	if err := json.Unmarshal(body, page); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
@@ -0,0 +1,73 @@
+package urchin
+
+import (
+	"context"
+	"io"
+	urfs "urchinfs/dfstore"
+	"urchinfs/digest"
+	pkgobjectstorage "urchinfs/objectstorage"
+)
+
+func (urfs *urchinfs) GetObjectWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string, byteRange *urfs.Range) (io.ReadCloser, *pkgobjectstorage.ObjectMetadata, error) {
+	// The span ends when reader is closed, so that it covers reading data.
+	ctx, span := urfs.startSpan(ctx, "GetObject", endpoint, bucketName, objectKey, destPeerHost)
+	ctx, cancel := context.WithCancel(ctx)
+
+	reader, meta, err := processGetObject(ctx, urfs.dfs, endpoint, bucketName, objectKey, destPeerHost, byteRange)
+	if err != nil {
+		cancel()
+		endSpan(span, err)
+		return nil, nil, err
+	}
+
+	rc := &cancelReadCloser{ReadCloser: reader, Reader: reader, cancel: func() {
+		cancel()
+		span.End()
+	}}
+
+	// Only the whole object can be verified.
+	if urfs.verifyDigest && byteRange == nil && meta.Digest != "" {
+		d, err := digest.Parse(meta.Digest)
+		if err != nil {
+			rc.Close()
+			return nil, nil, err
+		}
+
+		if rc.Reader, err = digest.NewReader(reader, d); err != nil {
+			rc.Close()
+			return nil, nil, err
+		}
+	}
+
+	return rc, meta, nil
+}
+
+// cancelReadCloser reads from Reader, and closes ReadCloser
+// and cancels the context of request when it is closed.
+type cancelReadCloser struct {
+	io.ReadCloser
+	io.Reader
+	cancel context.CancelFunc
+}
+
+// Read reads from Reader, which may verify data of ReadCloser.
+func (r *cancelReadCloser) Read(p []byte) (int, error) {
+	return r.Reader.Read(p)
+}
+
+// Close closes the reader and cancels the context.
+func (r *cancelReadCloser) Close() error {
+	defer r.cancel()
+	return r.ReadCloser.Close()
+}
+
+// Get object data cached in peer.
+func processGetObject(ctx context.Context, dfs urfs.Dfstore, endpoint, bucketName, objectKey, dstPeer string, byteRange *urfs.Range) (io.ReadCloser, *pkgobjectstorage.ObjectMetadata, error) {
+	return dfs.GetObjectWithContext(ctx, &urfs.GetUrfsInput{
+		Endpoint:   endpoint,
+		BucketName: bucketName,
+		ObjectKey:  objectKey,
+		DstPeer:    dstPeer,
+		ByteRange:  byteRange,
+	})
+}
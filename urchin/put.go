@@ -0,0 +1,89 @@
+package urchin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"urchinfs/config"
+	urfs "urchinfs/dfstore"
+)
+
+func (urfs *urchinfs) PutObjectWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost, filePath string) error {
+	return urfs.trace(ctx, "PutObject", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) error {
+		return processPutObject(ctx, urfs.dfs, urfs.cfg, endpoint, bucketName, objectKey, destPeerHost, filePath)
+	})
+}
+
+func (urfs *urchinfs) PutDirWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost, dirPath string) error {
+	return urfs.trace(ctx, "PutDir", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) error {
+		return urfs.putDir(ctx, endpoint, bucketName, objectKey, destPeerHost, dirPath)
+	})
+}
+
+// putDir puts files in local dir through peer, every file is put in its own span.
+func (urfs *urchinfs) putDir(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost, dirPath string) error {
+	return filepath.WalkDir(dirPath, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dirPath, filePath)
+		if err != nil {
+			return err
+		}
+
+		key := path.Join(objectKey, filepath.ToSlash(rel))
+		urfs.logf("put %s to %s/%s/%s through peer %s", filePath, endpoint, bucketName, key, destPeerHost)
+		return urfs.trace(ctx, "PutObject", endpoint, bucketName, key, destPeerHost, func(ctx context.Context) error {
+			return processPutObject(ctx, urfs.dfs, urfs.cfg, endpoint, bucketName, key, destPeerHost, filePath)
+		})
+	})
+}
+
+// Put local file to object storage through peer.
+func processPutObject(ctx context.Context, dfs urfs.Dfstore, cfg *config.DfstoreConfig, endpoint, bucketName, objectKey, dstPeer, filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Hash file before upload, so that peer receives its digest in header.
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	return dfs.PutObjectWithContext(ctx, &urfs.PutObjectInput{
+		Endpoint:      endpoint,
+		BucketName:    bucketName,
+		ObjectKey:     objectKey,
+		Filter:        cfg.Filter,
+		DstPeer:       dstPeer,
+		Mode:          cfg.Mode,
+		MaxReplicas:   cfg.MaxReplicas,
+		Reader:        f,
+		ContentLength: info.Size(),
+		ContentType:   mime.TypeByExtension(filepath.Ext(filePath)),
+		Digest:        "sha256:" + hex.EncodeToString(h.Sum(nil)),
+	})
+}
@@ -0,0 +1,98 @@
+package urchin
+
+import "fmt"
+
+// TaskStatus is the status of a schedule task on peer.
+//
+// Peer reports the status by PeerResult.StatusCode, the mapping is:
+//
+//	0     TaskSucceeded  data is cached on peer and ready to read
+//	1     TaskPending    task is accepted and waiting to start
+//	2     TaskRunning    data is being transferred to peer
+//	3     TaskFailed     task failed, StatusMsg tells the reason
+//	4     TaskNotFound   peer has no such task or data
+//	other TaskUnknown
+type TaskStatus int
+
+const (
+	// TaskUnknown means the peer reports an unrecognized code.
+	TaskUnknown TaskStatus = iota
+
+	// TaskPending means the task is accepted and waiting to start.
+	TaskPending
+
+	// TaskRunning means the data is being transferred to peer.
+	TaskRunning
+
+	// TaskSucceeded means the data is cached on peer.
+	TaskSucceeded
+
+	// TaskFailed means the task failed.
+	TaskFailed
+
+	// TaskNotFound means peer has no such task or data.
+	TaskNotFound
+)
+
+// Status codes reported by peer.
+const (
+	peerStatusCodeSucceeded = 0
+	peerStatusCodePending   = 1
+	peerStatusCodeRunning   = 2
+	peerStatusCodeFailed    = 3
+	peerStatusCodeNotFound  = 4
+)
+
+// TaskStatusFromCode returns TaskStatus of the status code reported by peer.
+func TaskStatusFromCode(code int) TaskStatus {
+	switch code {
+	case peerStatusCodeSucceeded:
+		return TaskSucceeded
+	case peerStatusCodePending:
+		return TaskPending
+	case peerStatusCodeRunning:
+		return TaskRunning
+	case peerStatusCodeFailed:
+		return TaskFailed
+	case peerStatusCodeNotFound:
+		return TaskNotFound
+	default:
+		return TaskUnknown
+	}
+}
+
+// String returns name of the status.
+func (s TaskStatus) String() string {
+	switch s {
+	case TaskPending:
+		return "Pending"
+	case TaskRunning:
+		return "Running"
+	case TaskSucceeded:
+		return "Succeeded"
+	case TaskFailed:
+		return "Failed"
+	case TaskNotFound:
+		return "NotFound"
+	case TaskUnknown:
+		return "Unknown"
+	default:
+		return fmt.Sprintf("TaskStatus(%d)", int(s))
+	}
+}
+
+// IsTerminal returns whether the status will not change without a new schedule,
+// unknown status is terminal so that callers never wait on it forever.
+func (s TaskStatus) IsTerminal() bool {
+	return s != TaskPending && s != TaskRunning
+}
+
+// IsSuccess returns whether the data is cached on peer.
+func (s TaskStatus) IsSuccess() bool {
+	return s == TaskSucceeded
+}
+
+// Retryable returns whether scheduling the data again may succeed.
+func (s TaskStatus) Retryable() bool {
+	return s == TaskFailed || s == TaskNotFound
+}
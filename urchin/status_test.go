@@ -0,0 +1,53 @@
+package urchin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTaskStatusFromCode(t *testing.T) {
+	tests := []struct {
+		code      int
+		expect    TaskStatus
+		name      string
+		terminal  bool
+		success   bool
+		retryable bool
+	}{
+		{code: 0, expect: TaskSucceeded, name: "Succeeded", terminal: true, success: true},
+		{code: 1, expect: TaskPending, name: "Pending"},
+		{code: 2, expect: TaskRunning, name: "Running"},
+		{code: 3, expect: TaskFailed, name: "Failed", terminal: true, retryable: true},
+		{code: 4, expect: TaskNotFound, name: "NotFound", terminal: true, retryable: true},
+		{code: 5, expect: TaskUnknown, name: "Unknown", terminal: true},
+		{code: -1, expect: TaskUnknown, name: "Unknown", terminal: true},
+	}
+
+	for _, tt := range tests {
+		status := TaskStatusFromCode(tt.code)
+		if status != tt.expect {
+			t.Errorf("TaskStatusFromCode(%d) = %s, expected %s", tt.code, status, tt.expect)
+			continue
+		}
+
+		if status.String() != tt.name {
+			t.Errorf("TaskStatusFromCode(%d).String() = %s, expected %s", tt.code, status, tt.name)
+		}
+
+		if status.IsTerminal() != tt.terminal || status.IsSuccess() != tt.success || status.Retryable() != tt.retryable {
+			t.Errorf("unexpected predicates of %s: terminal %t, success %t, retryable %t",
+				status, status.IsTerminal(), status.IsSuccess(), status.Retryable())
+		}
+	}
+}
+
+func TestPeerResultStatus(t *testing.T) {
+	result, err := decodePeerResult(strings.NewReader(`{"Content-Length": "5", "StatusCode": 2, "StatusMsg": "running"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Status() != TaskRunning || result.ContentLength != "5" || result.Size != 5 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
@@ -0,0 +1,102 @@
+package urchin
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation name of spans of operations.
+const tracerName = "urchinfs/urchin"
+
+// Attributes of operation spans.
+const (
+	attributeEndpoint   = attribute.Key("urchin.endpoint")
+	attributeBucketName = attribute.Key("urchin.bucket")
+	attributeObjectKey  = attribute.Key("urchin.object_key")
+	attributePeer       = attribute.Key("urchin.peer")
+	attributeTaskID     = attribute.Key("urchin.task_id")
+	attributeStatusCode = attribute.Key("urchin.status_code")
+
+	attributeDirScheduleMode = attribute.Key("urchin.dir_schedule_mode")
+)
+
+// WithTracerProvider set tracer provider of operation spans and request spans,
+// default is the global provider of otel. Requests to peers carry W3C trace context.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(urfs *urchinfs) {
+		urfs.tracerProvider = provider
+	}
+}
+
+// initTracing sets the default tracer provider and the tracer of operations.
+func (urfs *urchinfs) initTracing() {
+	if urfs.tracerProvider == nil {
+		urfs.tracerProvider = otel.GetTracerProvider()
+	}
+
+	urfs.tracer = urfs.tracerProvider.Tracer(tracerName)
+}
+
+// startSpan starts span of operation on object, peer is optional.
+func (urfs *urchinfs) startSpan(ctx context.Context, operation, endpoint, bucketName, objectKey, peer string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		attributeEndpoint.String(endpoint),
+		attributeBucketName.String(bucketName),
+		attributeObjectKey.String(objectKey),
+	}
+	if peer != "" {
+		attrs = append(attrs, attributePeer.String(peer))
+	}
+
+	return urfs.tracer.Start(ctx, "urchin."+operation, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends span with error of operation.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// trace calls fn in span of operation on object, peer is optional.
+func (urfs *urchinfs) trace(ctx context.Context, operation, endpoint, bucketName, objectKey, peer string, fn func(ctx context.Context) error) error {
+	ctx, span := urfs.startSpan(ctx, operation, endpoint, bucketName, objectKey, peer)
+	err := fn(ctx)
+	endSpan(span, err)
+
+	return err
+}
+
+// traceResult calls fn in span of operation on object, and records task of result.
+func (urfs *urchinfs) traceResult(ctx context.Context, operation, endpoint, bucketName, objectKey, peer string, fn func(ctx context.Context) (*PeerResult, error)) (*PeerResult, error) {
+	var result *PeerResult
+	err := urfs.trace(ctx, operation, endpoint, bucketName, objectKey, peer, func(ctx context.Context) error {
+		var err error
+		if result, err = fn(ctx); err != nil {
+			return err
+		}
+		setResultAttributes(trace.SpanFromContext(ctx), result)
+
+		return nil
+	})
+
+	return result, err
+}
+
+// setResultAttributes records task and status of result in span.
+func setResultAttributes(span trace.Span, result *PeerResult) {
+	if result == nil {
+		return
+	}
+
+	span.SetAttributes(
+		attributeTaskID.String(result.TaskID),
+		attributeStatusCode.Int(result.StatusCode),
+	)
+}
@@ -5,12 +5,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"go.opentelemetry.io/otel/trace"
+	"io"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 	"urchinfs/config"
 	urfs "urchinfs/dfstore"
+	pkgobjectstorage "urchinfs/objectstorage"
+	"urchinfs/peerpool"
 )
 
 type Urchinfs interface {
@@ -18,30 +24,289 @@ type Urchinfs interface {
 	// schedule source dataset to target peer
 	ScheduleDataToPeer(sourceUrl, destPeerHost string) (*PeerResult, error)
 
+	// ScheduleDataToPeerWithContext schedule source dataset to target peer with context.
+	ScheduleDataToPeerWithContext(ctx context.Context, sourceUrl, destPeerHost string) (*PeerResult, error)
+
 	// check schedule data to peer task status
 	CheckScheduleTaskStatus(sourceUrl, destPeerHost string) (*PeerResult, error)
 
+	// CheckScheduleTaskStatusWithContext check schedule data to peer task status with context.
+	CheckScheduleTaskStatusWithContext(ctx context.Context, sourceUrl, destPeerHost string) (*PeerResult, error)
+
 	ScheduleDataToPeerByKey(endpoint, bucketName, objectKey, destPeerHost string, overwrite bool) (*PeerResult, error)
 
+	// ScheduleDataToPeerByKeyWithContext schedule object to target peer with context.
+	ScheduleDataToPeerByKeyWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string, overwrite bool) (*PeerResult, error)
+
 	CheckScheduleTaskStatusByKey(endpoint, bucketName, objectKey, destPeerHost string) (*PeerResult, error)
 
+	// CheckScheduleTaskStatusByKeyWithContext check schedule object task status with context.
+	CheckScheduleTaskStatusByKeyWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string) (*PeerResult, error)
+
 	ScheduleDirToPeerByKey(endpoint, bucketName, objectKey, destPeerHost string) (*PeerResult, error)
 
+	// ScheduleDirToPeerByKeyWithContext schedule dir to target peer with context.
+	ScheduleDirToPeerByKeyWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string) (*PeerResult, error)
+
+	// ScheduleDirToPeerByKeyWithModeContext schedule dir to target peer in mode,
+	// ScheduleDirToPeerByKeyWithContext is the same as DirScheduleSkipExisting.
+	ScheduleDirToPeerByKeyWithModeContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string, mode DirScheduleMode) (*PeerResult, error)
+
+	// ScheduleDataToAnyPeerByKeyWithContext schedule object to the best healthy peer of
+	// peer pool, it fails over to other peers if peer is unavailable and returns the peer used.
+	ScheduleDataToAnyPeerByKeyWithContext(ctx context.Context, endpoint, bucketName, objectKey string, overwrite bool) (string, *PeerResult, error)
+
+	// ScheduleDirToAnyPeerByKeyWithContext schedule dir to the best healthy peer of
+	// peer pool, it fails over to other peers if peer is unavailable and returns the peer used.
+	ScheduleDirToAnyPeerByKeyWithContext(ctx context.Context, endpoint, bucketName, objectKey string) (string, *PeerResult, error)
+
 	CheckScheduleDirTaskStatusByKey(endpoint, bucketName, objectKey, destPeerHost string) (*PeerResult, error)
+
+	// CheckScheduleDirTaskStatusByKeyWithContext check schedule dir task status with context.
+	CheckScheduleDirTaskStatusByKeyWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string) (*PeerResult, error)
+
+	// GetMetadataWithContext returns metadata of object through target peer.
+	GetMetadataWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string) (*pkgobjectstorage.ObjectMetadata, error)
+
+	// StatObjectWithContext returns cache status of object in target peer,
+	// error matches dfstore.ErrNotFound if object is not cached.
+	StatObjectWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string) (*PeerResult, error)
+
+	// ImportObjectWithContext imports local file to target peer cache as the object.
+	ImportObjectWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost, filePath string) (*PeerResult, error)
+
+	// ExportObjectWithContext exports object in target peer cache to local file.
+	ExportObjectWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost, outputPath string) error
+
+	// ExportObjectToBucketWithContext exports object in target peer cache to the target bucket.
+	ExportObjectToBucketWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost, targetEndpoint, targetBucketName, targetObjectKey string) (*PeerResult, error)
+
+	// DeleteObjectWithContext deletes object from target peer cache.
+	DeleteObjectWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string) error
+
+	// PutObjectWithContext puts local file to object storage through target peer,
+	// it returns according to the write mode of dfstore config. The sha256 digest
+	// of file is sent in X-Dragonfly-Object-Meta-Digest.
+	PutObjectWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost, filePath string) error
+
+	// PutDirWithContext puts files in local dir to object storage through target peer,
+	// objectKey is the prefix of object keys.
+	PutDirWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost, dirPath string) error
+
+	// GetObjectWithContext returns object data cached in target peer and its metadata,
+	// byteRange is optional, the context of request is canceled when reader is closed.
+	GetObjectWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string, byteRange *urfs.Range) (io.ReadCloser, *pkgobjectstorage.ObjectMetadata, error)
+
+	// VerifyObjectWithContext verifies object data cached in target peer with its digest.
+	VerifyObjectWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string) error
+
+	// ListDirWithContext returns files of folder and their cache status in target peer,
+	// all pages of listing are fetched.
+	ListDirWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string) (*DirManifest, error)
+
+	// WalkDirWithContext calls fn for every file of folder page by page,
+	// which does not hold the whole listing of very large folders in memory.
+	WalkDirWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string, fn WalkDirFunc) error
+
+	// WaitForSchedule polls schedule task status until the task is finished or timeout.
+	WaitForSchedule(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string, opts *WaitOptions) (*PeerResult, error)
 }
 
 type urchinfs struct {
 	// Initialize default urfs config.
 	cfg *config.DfstoreConfig
+
+	// dfs is the dfstore client shared by all operations.
+	dfs urfs.Dfstore
+
+	httpClient      *http.Client
+	requestTimeout  time.Duration
+	scheduleTimeout time.Duration
+	timeoutsSet     bool
+	logger          Logger
+	dfsOptions      []urfs.Option
+	verifyDigest    bool
+	pool            *peerpool.Pool
+	metrics         Metrics
+	tracerProvider  trace.TracerProvider
+	tracer          trace.Tracer
+
+	// profile is loaded by config.LoadDfstore if set.
+	profile *profileOption
+}
+
+// profileOption is the config file and profile selected by WithProfile.
+type profileOption struct {
+	path string
+	name string
+}
+
+// Logger is the interface used for printing debug messages, *log.Logger satisfies it.
+type Logger = urfs.Logger
+
+// Option is a functional option for configuring the urchinfs.
+type Option func(urfs *urchinfs)
+
+// WithConfig set dfstore config for urchinfs.
+func WithConfig(cfg *config.DfstoreConfig) Option {
+	return func(urfs *urchinfs) {
+		urfs.cfg = cfg
+	}
+}
+
+// WithHTTPClient set http client used to request peers.
+func WithHTTPClient(client *http.Client) Option {
+	return func(urfs *urchinfs) {
+		urfs.httpClient = client
+	}
+}
+
+// WithProfile loads dfstore config of profile from config file by config.LoadDfstore,
+// empty path and name select the defaults. It takes precedence over WithConfig.
+func WithProfile(path, name string) Option {
+	return func(urfs *urchinfs) {
+		urfs.profile = &profileOption{path: path, name: name}
+	}
+}
+
+// WithTimeouts set the timeout of a single operation and the timeout
+// of waiting for a schedule task, zero requestTimeout means no limit.
+// Operations streaming object data, i.e. get, verify, put, import and export,
+// are bounded by requestTimeout only until response header is received,
+// their data is bounded by the context. They take precedence over the
+// timeouts of dfstore config.
+func WithTimeouts(requestTimeout, scheduleTimeout time.Duration) Option {
+	return func(urfs *urchinfs) {
+		urfs.requestTimeout = requestTimeout
+		urfs.scheduleTimeout = scheduleTimeout
+		urfs.timeoutsSet = true
+	}
+}
+
+// WithLogger set logger for urchinfs.
+func WithLogger(logger Logger) Option {
+	return func(urfs *urchinfs) {
+		urfs.logger = logger
+	}
+}
+
+// Metrics observes requests to peers and bytes scheduled.
+type Metrics = urfs.Metrics
+
+// WithMetrics set metrics observing requests to peers and bytes scheduled,
+// see package metrics for the Prometheus implementation.
+func WithMetrics(metrics Metrics) Option {
+	return func(urfs *urchinfs) {
+		urfs.metrics = metrics
+	}
+}
+
+// WithDfstoreOptions set options passed through to the dfstore client,
+// they take precedence over WithHTTPClient and WithLogger.
+func WithDfstoreOptions(options ...urfs.Option) Option {
+	return func(urfs *urchinfs) {
+		urfs.dfsOptions = append(urfs.dfsOptions, options...)
+	}
 }
 
 // New urchinfs instance.
-func New() Urchinfs {
+func New(options ...Option) (Urchinfs, error) {
+	ufs := &urchinfs{
+		cfg:        config.NewDfstore(),
+		httpClient: http.DefaultClient,
+	}
+
+	for _, opt := range options {
+		opt(ufs)
+	}
 
-	urfs := &urchinfs{
-		cfg: config.NewDfstore(),
+	if ufs.profile != nil {
+		cfg, err := config.LoadDfstore(ufs.profile.path, ufs.profile.name)
+		if err != nil {
+			return nil, err
+		}
+		ufs.cfg = cfg
+	}
+
+	if !ufs.timeoutsSet && ufs.cfg != nil {
+		ufs.requestTimeout = ufs.cfg.RequestTimeout
+		ufs.scheduleTimeout = ufs.cfg.ScheduleTimeout
+		if ufs.scheduleTimeout == 0 {
+			ufs.scheduleTimeout = config.DefaultScheduleTimeout
+		}
+	}
+
+	if err := ufs.validate(); err != nil {
+		return nil, err
+	}
+	ufs.initTracing()
+
+	dfsOptions := []urfs.Option{
+		urfs.WithHTTPClient(ufs.httpClient),
+		urfs.WithPattern(ufs.cfg.Pattern),
+		urfs.WithTag(ufs.cfg.Tag),
+		urfs.WithApplication(ufs.cfg.Application),
+		urfs.WithTracerProvider(ufs.tracerProvider),
+		urfs.WithRequestTimeout(ufs.requestTimeout),
+	}
+	if ufs.logger != nil {
+		dfsOptions = append(dfsOptions, urfs.WithLogger(ufs.logger))
+	}
+	if ufs.metrics != nil {
+		dfsOptions = append(dfsOptions, urfs.WithMetrics(ufs.metrics))
+	}
+	dfsOptions = append(dfsOptions, ufs.dfsOptions...)
+	ufs.dfs = urfs.New(ufs.cfg.Endpoint, dfsOptions...)
+
+	return ufs, nil
+}
+
+// validate validates urchinfs options.
+func (urfs *urchinfs) validate() error {
+	if urfs.cfg == nil {
+		return errors.New("urchinfs requires dfstore config")
+	}
+
+	if err := urfs.cfg.Validate(); err != nil {
+		return err
+	}
+
+	if urfs.httpClient == nil {
+		return errors.New("urchinfs requires http client")
+	}
+
+	if urfs.requestTimeout < 0 {
+		return fmt.Errorf("invalid request timeout %s", urfs.requestTimeout)
+	}
+
+	if urfs.scheduleTimeout <= 0 {
+		return fmt.Errorf("invalid schedule timeout %s", urfs.scheduleTimeout)
+	}
+
+	return nil
+}
+
+// withTimeout returns a context bounded by the request timeout.
+func (urfs *urchinfs) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if urfs.requestTimeout == 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, urfs.requestTimeout)
+}
+
+// recordScheduled reports size of schedule task accepted by peer to metrics.
+func (urfs *urchinfs) recordScheduled(peer string, result *PeerResult) {
+	if urfs.metrics != nil && result.Size > 0 {
+		urfs.metrics.BytesScheduled(peer, result.Size)
+	}
+}
+
+// logf prints debug message if logger is set.
+func (urfs *urchinfs) logf(format string, v ...interface{}) {
+	if urfs.logger != nil {
+		urfs.logger.Printf(format, v...)
 	}
-	return urfs
 }
 
 const (
@@ -50,101 +315,133 @@ const (
 )
 
 func (urfs *urchinfs) ScheduleDataToPeer(sourceUrl, destPeerHost string) (*PeerResult, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	if err := urfs.cfg.Validate(); err != nil {
-		return nil, err
-	}
+	return urfs.ScheduleDataToPeerWithContext(context.Background(), sourceUrl, destPeerHost)
+}
 
+func (urfs *urchinfs) ScheduleDataToPeerWithContext(ctx context.Context, sourceUrl, destPeerHost string) (*PeerResult, error) {
 	if err := validateSchedulelArgs(sourceUrl, destPeerHost); err != nil {
 		return nil, err
 	}
 
 	// Copy object storage to local file.
-	endpoint, bucketName, objectKey, err := parseUrfsURL(sourceUrl)
-	if err != nil {
-		return nil, err
-	}
-	peerResult, err := processScheduleDataToPeer(ctx, urfs.cfg, endpoint, bucketName, objectKey, destPeerHost, false)
+	endpoint, bucketName, objectKey, err := ParseUrfsURL(sourceUrl)
 	if err != nil {
 		return nil, err
 	}
 
-	return peerResult, err
+	return urfs.traceResult(ctx, "ScheduleDataToPeer", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) (*PeerResult, error) {
+		ctx, cancel := urfs.withTimeout(ctx)
+		defer cancel()
+
+		peerResult, err := processScheduleDataToPeer(ctx, urfs.dfs, endpoint, bucketName, objectKey, destPeerHost, false)
+		if err != nil {
+			return nil, err
+		}
+		urfs.recordScheduled(destPeerHost, peerResult)
+
+		return peerResult, err
+	})
 }
 
 func (urfs *urchinfs) ScheduleDataToPeerByKey(endpoint, bucketName, objectKey, destPeerHost string, overwrite bool) (*PeerResult, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	return urfs.ScheduleDataToPeerByKeyWithContext(context.Background(), endpoint, bucketName, objectKey, destPeerHost, overwrite)
+}
 
-	peerResult, err := processScheduleDataToPeer(ctx, urfs.cfg, endpoint, bucketName, objectKey, destPeerHost, overwrite)
-	if err != nil {
-		return nil, err
-	}
+func (urfs *urchinfs) ScheduleDataToPeerByKeyWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string, overwrite bool) (*PeerResult, error) {
+	return urfs.traceResult(ctx, "ScheduleDataToPeerByKey", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) (*PeerResult, error) {
+		ctx, cancel := urfs.withTimeout(ctx)
+		defer cancel()
 
-	return peerResult, err
+		peerResult, err := processScheduleDataToPeer(ctx, urfs.dfs, endpoint, bucketName, objectKey, destPeerHost, overwrite)
+		if err != nil {
+			return nil, err
+		}
+		urfs.recordScheduled(destPeerHost, peerResult)
+
+		return peerResult, err
+	})
 }
 
 func (urfs *urchinfs) ScheduleDirToPeerByKey(endpoint, bucketName, objectKey, destPeerHost string) (*PeerResult, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	return urfs.ScheduleDirToPeerByKeyWithContext(context.Background(), endpoint, bucketName, objectKey, destPeerHost)
+}
 
-	peerResult, err := processScheduleDirToPeer(ctx, urfs.cfg, endpoint, bucketName, objectKey, destPeerHost)
-	if err != nil {
-		return nil, err
-	}
+func (urfs *urchinfs) ScheduleDirToPeerByKeyWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string) (*PeerResult, error) {
+	return urfs.traceResult(ctx, "ScheduleDirToPeerByKey", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) (*PeerResult, error) {
+		ctx, cancel := urfs.withTimeout(ctx)
+		defer cancel()
+
+		peerResult, err := processScheduleDirToPeer(ctx, urfs.dfs, endpoint, bucketName, objectKey, destPeerHost, false)
+		if err != nil {
+			return nil, err
+		}
+		urfs.recordScheduled(destPeerHost, peerResult)
 
-	return peerResult, err
+		return peerResult, err
+	})
 }
 
 func (urfs *urchinfs) CheckScheduleTaskStatus(sourceUrl, destPeerHost string) (*PeerResult, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	if err := urfs.cfg.Validate(); err != nil {
-		return nil, err
-	}
+	return urfs.CheckScheduleTaskStatusWithContext(context.Background(), sourceUrl, destPeerHost)
+}
 
+func (urfs *urchinfs) CheckScheduleTaskStatusWithContext(ctx context.Context, sourceUrl, destPeerHost string) (*PeerResult, error) {
 	if err := validateSchedulelArgs(sourceUrl, destPeerHost); err != nil {
 		return nil, err
 	}
 
 	// Copy object storage to local file.
-	endpoint, bucketName, objectKey, err := parseUrfsURL(sourceUrl)
-	if err != nil {
-		return nil, err
-	}
-	peerResult, err := processCheckScheduleTaskStatus(ctx, urfs.cfg, endpoint, bucketName, objectKey, destPeerHost)
+	endpoint, bucketName, objectKey, err := ParseUrfsURL(sourceUrl)
 	if err != nil {
 		return nil, err
 	}
 
-	return peerResult, err
+	return urfs.traceResult(ctx, "CheckScheduleTaskStatus", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) (*PeerResult, error) {
+		ctx, cancel := urfs.withTimeout(ctx)
+		defer cancel()
+
+		return processCheckScheduleTaskStatus(ctx, urfs.dfs, endpoint, bucketName, objectKey, destPeerHost)
+	})
 }
 
 func (urfs *urchinfs) CheckScheduleTaskStatusByKey(endpoint, bucketName, objectKey, destPeerHost string) (*PeerResult, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	return urfs.CheckScheduleTaskStatusByKeyWithContext(context.Background(), endpoint, bucketName, objectKey, destPeerHost)
+}
 
-	peerResult, err := processCheckScheduleTaskStatus(ctx, urfs.cfg, endpoint, bucketName, objectKey, destPeerHost)
-	if err != nil {
-		return nil, err
-	}
+func (urfs *urchinfs) CheckScheduleTaskStatusByKeyWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string) (*PeerResult, error) {
+	return urfs.traceResult(ctx, "CheckScheduleTaskStatusByKey", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) (*PeerResult, error) {
+		ctx, cancel := urfs.withTimeout(ctx)
+		defer cancel()
 
-	return peerResult, err
+		return processCheckScheduleTaskStatus(ctx, urfs.dfs, endpoint, bucketName, objectKey, destPeerHost)
+	})
 }
 
 func (urfs *urchinfs) CheckScheduleDirTaskStatusByKey(endpoint, bucketName, objectKey, destPeerHost string) (*PeerResult, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	return urfs.CheckScheduleDirTaskStatusByKeyWithContext(context.Background(), endpoint, bucketName, objectKey, destPeerHost)
+}
 
-	peerResult, err := processCheckScheduleDirTaskStatus(ctx, urfs.cfg, endpoint, bucketName, objectKey, destPeerHost)
-	if err != nil {
-		return nil, err
-	}
+func (urfs *urchinfs) CheckScheduleDirTaskStatusByKeyWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string) (*PeerResult, error) {
+	return urfs.traceResult(ctx, "CheckScheduleDirTaskStatusByKey", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) (*PeerResult, error) {
+		ctx, cancel := urfs.withTimeout(ctx)
+		defer cancel()
+
+		return processCheckScheduleDirTaskStatus(ctx, urfs.dfs, endpoint, bucketName, objectKey, destPeerHost)
+	})
+}
 
-	return peerResult, err
+func (urfs *urchinfs) GetMetadataWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string) (*pkgobjectstorage.ObjectMetadata, error) {
+	var meta *pkgobjectstorage.ObjectMetadata
+	err := urfs.trace(ctx, "GetMetadata", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) error {
+		ctx, cancel := urfs.withTimeout(ctx)
+		defer cancel()
+
+		var err error
+		meta, err = processGetMetadata(ctx, urfs.dfs, endpoint, bucketName, objectKey, destPeerHost)
+		return err
+	})
+
+	return meta, err
 }
 
 // isUrfsURL determines whether the raw url is urfs url.
@@ -170,8 +467,8 @@ func validateSchedulelArgs(sourceUrl, destPeer string) error {
 	return nil
 }
 
-// Parse object storage url. eg: urfs://源数据$endpoint/源数据$bucket/源数据filepath
-func parseUrfsURL(rawURL string) (string, string, string, error) {
+// ParseUrfsURL parses object storage url to endpoint, bucket and object key. eg: urfs://源数据$endpoint/源数据$bucket/源数据filepath
+func ParseUrfsURL(rawURL string) (string, string, string, error) {
 	u, err := url.ParseRequestURI(rawURL)
 	if err != nil {
 		return "", "", "", err
@@ -199,9 +496,18 @@ func parseUrfsURL(rawURL string) (string, string, string, error) {
 	return u.Host, bucket, key, nil
 }
 
+// Get object metadata through peer.
+func processGetMetadata(ctx context.Context, dfs urfs.Dfstore, endpoint, bucketName, objectKey, dstPeer string) (*pkgobjectstorage.ObjectMetadata, error) {
+	return dfs.GetUrfsMetadataWithContext(ctx, &urfs.GetUrfsMetadataInput{
+		Endpoint:   endpoint,
+		BucketName: bucketName,
+		ObjectKey:  objectKey,
+		DstPeer:    dstPeer,
+	}, false)
+}
+
 // Schedule object storage to peer.
-func processScheduleDataToPeer(ctx context.Context, cfg *config.DfstoreConfig, endpoint, bucketName, objectKey, dstPeer string, overwrite bool) (*PeerResult, error) {
-	dfs := urfs.New(cfg.Endpoint)
+func processScheduleDataToPeer(ctx context.Context, dfs urfs.Dfstore, endpoint, bucketName, objectKey, dstPeer string, overwrite bool) (*PeerResult, error) {
 	meta, err := dfs.GetUrfsMetadataWithContext(ctx, &urfs.GetUrfsMetadataInput{
 		Endpoint:   endpoint,
 		BucketName: bucketName,
@@ -224,54 +530,42 @@ func processScheduleDataToPeer(ctx context.Context, cfg *config.DfstoreConfig, e
 	}
 	defer reader.Close()
 
-	body, err := ioutil.ReadAll(reader)
-
-	var peerResult PeerResult
-	if err == nil {
-		err = json.Unmarshal((body), &peerResult)
-	}
-	peerResult.SignedUrl = strings.ReplaceAll(peerResult.SignedUrl, "\\u0026", "&")
-
-	fileContentLength, err := strconv.ParseInt(peerResult.ContentLength, 10, 64)
+	peerResult, err := decodePeerResult(reader)
 	if err != nil {
 		return nil, err
 	}
-	if fileContentLength != meta.ContentLength {
-		return nil, errors.New("content length inconsistent with meta")
+
+	if peerResult.ContentLength == "" {
+		return nil, errors.New("peer reports no content length")
+	}
+
+	if peerResult.Size != meta.ContentLength {
+		return nil, fmt.Errorf("%w: peer %d, meta %d", urfs.ErrContentLengthMismatch, peerResult.Size, meta.ContentLength)
 	}
 
-	return &peerResult, err
+	return peerResult, nil
 }
 
 // Schedule object storage dir to peer.
-func processScheduleDirToPeer(ctx context.Context, cfg *config.DfstoreConfig, endpoint, bucketName, objectKey, dstPeer string) (*PeerResult, error) {
-	dfs := urfs.New(cfg.Endpoint)
+func processScheduleDirToPeer(ctx context.Context, dfs urfs.Dfstore, endpoint, bucketName, objectKey, dstPeer string, overwrite bool) (*PeerResult, error) {
 
 	reader, err := dfs.GetUrfsWithContext(ctx, &urfs.GetUrfsInput{
 		Endpoint:   endpoint,
 		BucketName: bucketName,
 		ObjectKey:  objectKey,
 		DstPeer:    dstPeer,
+		Overwrite:  overwrite,
 	}, true)
 	if err != nil {
 		return nil, err
 	}
 	defer reader.Close()
 
-	body, err := ioutil.ReadAll(reader)
-
-	var peerResult PeerResult
-	if err == nil {
-		err = json.Unmarshal((body), &peerResult)
-	}
-	peerResult.SignedUrl = strings.ReplaceAll(peerResult.SignedUrl, "\\u0026", "&")
-
-	return &peerResult, err
+	return decodePeerResult(reader)
 }
 
 // check schedule task status.
-func processCheckScheduleTaskStatus(ctx context.Context, cfg *config.DfstoreConfig, endpoint, bucketName, objectKey, dstPeer string) (*PeerResult, error) {
-	dfs := urfs.New(cfg.Endpoint)
+func processCheckScheduleTaskStatus(ctx context.Context, dfs urfs.Dfstore, endpoint, bucketName, objectKey, dstPeer string) (*PeerResult, error) {
 	meta, err := dfs.GetUrfsMetadataWithContext(ctx, &urfs.GetUrfsMetadataInput{
 		Endpoint:   endpoint,
 		BucketName: bucketName,
@@ -293,27 +587,24 @@ func processCheckScheduleTaskStatus(ctx context.Context, cfg *config.DfstoreConf
 	}
 	defer reader.Close()
 
-	body, err := ioutil.ReadAll(reader)
-
-	var peerResult PeerResult
-	if err == nil {
-		err = json.Unmarshal((body), &peerResult)
-	}
-	peerResult.SignedUrl = strings.ReplaceAll(peerResult.SignedUrl, "\\u0026", "&")
-
-	fileContentLength, err := strconv.ParseInt(peerResult.ContentLength, 10, 64)
+	peerResult, err := decodePeerResult(reader)
 	if err != nil {
 		return nil, err
 	}
-	if fileContentLength != meta.ContentLength {
-		return nil, errors.New("content length inconsistent with meta")
+
+	if peerResult.ContentLength == "" {
+		return nil, errors.New("peer reports no content length")
+	}
+
+	if peerResult.Size != meta.ContentLength {
+		return nil, fmt.Errorf("%w: peer %d, meta %d", urfs.ErrContentLengthMismatch, peerResult.Size, meta.ContentLength)
 	}
-	return &peerResult, err
+
+	return peerResult, nil
 }
 
 // check schedule task status.
-func processCheckScheduleDirTaskStatus(ctx context.Context, cfg *config.DfstoreConfig, endpoint, bucketName, objectKey, dstPeer string) (*PeerResult, error) {
-	dfs := urfs.New(cfg.Endpoint)
+func processCheckScheduleDirTaskStatus(ctx context.Context, dfs urfs.Dfstore, endpoint, bucketName, objectKey, dstPeer string) (*PeerResult, error) {
 
 	reader, err := dfs.GetUrfsStatusWithContext(ctx, &urfs.GetUrfsInput{
 		Endpoint:   endpoint,
@@ -326,24 +617,53 @@ func processCheckScheduleDirTaskStatus(ctx context.Context, cfg *config.DfstoreC
 	}
 	defer reader.Close()
 
+	return decodePeerResult(reader)
+}
+
+type PeerResult struct {
+	ContentType string `json:"Content-Type"`
+
+	ContentLength string `json:"Content-Length"`
+
+	// Size is parsed from ContentLength, it is zero if peer reports none.
+	Size int64 `json:"-"`
+
+	SignedUrl    string
+	DataRoot     string
+	DataPath     string
+	DataEndpoint string
+	StatusCode   int
+	StatusMsg    string
+	TaskID       string
+
+	// BackSourceReason is the reason why peer downloaded from source instead of P2P.
+	BackSourceReason config.BackSourceReason
+}
+
+// Status returns typed status of StatusCode.
+func (r *PeerResult) Status() TaskStatus {
+	return TaskStatusFromCode(r.StatusCode)
+}
+
+// decodePeerResult decodes peer response body to PeerResult.
+func decodePeerResult(reader io.Reader) (*PeerResult, error) {
 	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
 
 	var peerResult PeerResult
-	if err == nil {
-		err = json.Unmarshal((body), &peerResult)
+	if err := json.Unmarshal(body, &peerResult); err != nil {
+		return nil, err
 	}
 	peerResult.SignedUrl = strings.ReplaceAll(peerResult.SignedUrl, "\\u0026", "&")
-	return &peerResult, err
-}
 
-type PeerResult struct {
-	ContentType   string `json:"Content-Type"`
-	ContentLength string `json:"Content-Length"`
-	SignedUrl     string
-	DataRoot      string
-	DataPath      string
-	DataEndpoint  string
-	StatusCode    int
-	StatusMsg     string
-	TaskID        string
+	if peerResult.ContentLength != "" {
+		peerResult.Size, err = strconv.ParseInt(peerResult.ContentLength, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid content length %q: %w", peerResult.ContentLength, err)
+		}
+	}
+
+	return &peerResult, nil
 }
@@ -0,0 +1,52 @@
+package urchin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	urfs "urchinfs/dfstore"
+	"urchinfs/digest"
+)
+
+// ErrNoDigest means the object has no digest to verify.
+var ErrNoDigest = errors.New("object has no digest")
+
+// WithDigestVerification verifies object data with X-Dragonfly-Object-Meta-Digest,
+// data read by GetObjectWithContext is hashed while streaming, and objects
+// are verified after WaitForSchedule succeeds. Objects without digest are
+// not verified, mismatch fails with error matching digest.ErrDigestMismatch.
+func WithDigestVerification() Option {
+	return func(urfs *urchinfs) {
+		urfs.verifyDigest = true
+	}
+}
+
+func (urfs *urchinfs) VerifyObjectWithContext(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string) error {
+	return urfs.trace(ctx, "VerifyObject", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) error {
+		return processVerifyObject(ctx, urfs.dfs, endpoint, bucketName, objectKey, destPeerHost)
+	})
+}
+
+// Verify object data cached in peer with its digest.
+func processVerifyObject(ctx context.Context, dfs urfs.Dfstore, endpoint, bucketName, objectKey, dstPeer string) error {
+	reader, meta, err := processGetObject(ctx, dfs, endpoint, bucketName, objectKey, dstPeer, nil)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if meta.Digest == "" {
+		return ErrNoDigest
+	}
+
+	d, err := digest.Parse(meta.Digest)
+	if err != nil {
+		return err
+	}
+
+	if err := d.Verify(reader); err != nil {
+		return fmt.Errorf("verify %s/%s/%s in peer %s: %w", endpoint, bucketName, objectKey, dstPeer, err)
+	}
+
+	return nil
+}
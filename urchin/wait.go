@@ -0,0 +1,192 @@
+package urchin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	// DefaultWaitInitialInterval is the default interval of the first status check.
+	DefaultWaitInitialInterval = 1 * time.Second
+
+	// DefaultWaitMaxInterval is the default upper bound of the status check interval.
+	DefaultWaitMaxInterval = 30 * time.Second
+
+	// DefaultWaitMultiplier is the default growth factor of the status check interval.
+	DefaultWaitMultiplier = 2.0
+)
+
+// WaitOptions is used to control how WaitForSchedule polls the task status.
+type WaitOptions struct {
+	// IsDir polls check_folder instead of check_object.
+	IsDir bool
+
+	// Timeout is the maximum duration of waiting,
+	// default is the schedule timeout of urchinfs.
+	Timeout time.Duration
+
+	// InitialInterval is the interval of the first status check.
+	InitialInterval time.Duration
+
+	// MaxInterval is the upper bound of the status check interval.
+	MaxInterval time.Duration
+
+	// Multiplier is the growth factor of the status check interval.
+	Multiplier float64
+
+	// OnProgress is called every time the task status changes.
+	OnProgress func(result *PeerResult)
+}
+
+// ScheduleTimeoutError is returned by WaitForSchedule if the task
+// is not finished within the timeout.
+type ScheduleTimeoutError struct {
+	Endpoint   string
+	BucketName string
+	ObjectKey  string
+	DstPeer    string
+	Timeout    time.Duration
+
+	// LastResult is the last polled status, it is nil if no status was polled.
+	LastResult *PeerResult
+}
+
+// Error implements error.
+func (e *ScheduleTimeoutError) Error() string {
+	return fmt.Sprintf("wait for schedule %s/%s/%s to peer %s timeout after %s", e.Endpoint, e.BucketName, e.ObjectKey, e.DstPeer, e.Timeout)
+}
+
+// Unwrap makes ScheduleTimeoutError match context.DeadlineExceeded.
+func (e *ScheduleTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// withDefaults returns a copy of options with zero fields set to defaults.
+func (o *WaitOptions) withDefaults(scheduleTimeout time.Duration) WaitOptions {
+	var opts WaitOptions
+	if o != nil {
+		opts = *o
+	}
+
+	if opts.Timeout <= 0 {
+		opts.Timeout = scheduleTimeout
+	}
+
+	if opts.InitialInterval <= 0 {
+		opts.InitialInterval = DefaultWaitInitialInterval
+	}
+
+	if opts.MaxInterval <= 0 {
+		opts.MaxInterval = DefaultWaitMaxInterval
+	}
+
+	if opts.MaxInterval < opts.InitialInterval {
+		opts.MaxInterval = opts.InitialInterval
+	}
+
+	if opts.Multiplier < 1 {
+		opts.Multiplier = DefaultWaitMultiplier
+	}
+
+	return opts
+}
+
+// WaitForSchedule polls the schedule task status with exponential backoff
+// until the task status is terminal, then returns the final result.
+func (urfs *urchinfs) WaitForSchedule(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string, opts *WaitOptions) (*PeerResult, error) {
+	return urfs.traceResult(ctx, "WaitForSchedule", endpoint, bucketName, objectKey, destPeerHost, func(ctx context.Context) (*PeerResult, error) {
+		return urfs.waitForSchedule(ctx, endpoint, bucketName, objectKey, destPeerHost, opts)
+	})
+}
+
+// waitForSchedule polls schedule task status, every poll is a child span.
+func (urfs *urchinfs) waitForSchedule(ctx context.Context, endpoint, bucketName, objectKey, destPeerHost string, opts *WaitOptions) (*PeerResult, error) {
+	o := opts.withDefaults(urfs.scheduleTimeout)
+
+	ctx, cancel := context.WithTimeout(ctx, o.Timeout)
+	defer cancel()
+
+	var (
+		last     *PeerResult
+		interval = o.InitialInterval
+	)
+	for {
+		var (
+			result *PeerResult
+			err    error
+		)
+		if o.IsDir {
+			result, err = urfs.CheckScheduleDirTaskStatusByKeyWithContext(ctx, endpoint, bucketName, objectKey, destPeerHost)
+		} else {
+			result, err = urfs.CheckScheduleTaskStatusByKeyWithContext(ctx, endpoint, bucketName, objectKey, destPeerHost)
+		}
+
+		if err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, newScheduleTimeoutError(endpoint, bucketName, objectKey, destPeerHost, o.Timeout, last)
+			}
+
+			return nil, err
+		}
+
+		if last == nil || last.StatusCode != result.StatusCode || last.StatusMsg != result.StatusMsg {
+			urfs.logf("schedule %s/%s/%s to peer %s status %s %s", endpoint, bucketName, objectKey, destPeerHost, result.Status(), result.StatusMsg)
+			if o.OnProgress != nil {
+				o.OnProgress(result)
+			}
+		}
+		last = result
+
+		if result.Status().IsTerminal() {
+			if urfs.verifyDigest && !o.IsDir && result.Status().IsSuccess() {
+				err := processVerifyObject(ctx, urfs.dfs, endpoint, bucketName, objectKey, destPeerHost)
+				if err != nil && !errors.Is(err, ErrNoDigest) {
+					return nil, err
+				}
+			}
+
+			return result, nil
+		}
+
+		timer := time.NewTimer(jitter(interval))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, newScheduleTimeoutError(endpoint, bucketName, objectKey, destPeerHost, o.Timeout, last)
+			}
+
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		interval = time.Duration(float64(interval) * o.Multiplier)
+		if interval > o.MaxInterval {
+			interval = o.MaxInterval
+		}
+	}
+}
+
+func newScheduleTimeoutError(endpoint, bucketName, objectKey, destPeerHost string, timeout time.Duration, last *PeerResult) error {
+	return &ScheduleTimeoutError{
+		Endpoint:   endpoint,
+		BucketName: bucketName,
+		ObjectKey:  objectKey,
+		DstPeer:    destPeerHost,
+		Timeout:    timeout,
+		LastResult: last,
+	}
+}
+
+// jitter returns a random duration in [interval/2, interval).
+func jitter(interval time.Duration) time.Duration {
+	half := int64(interval / 2)
+	if half <= 0 {
+		return interval
+	}
+
+	return time.Duration(half + rand.Int63n(half))
+}